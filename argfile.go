@@ -0,0 +1,60 @@
+package easy
+
+import "strings"
+
+// argRef tells where a positional argument comes from.
+type argRef struct {
+	file string // Empty for an argument given on the command line.
+	line int
+}
+
+// expandArgFiles replaces every argument @path in args with the lines
+// of path, which is read by Open (so "@-" reads stdin and
+// "@list.gz" is decompressed). Each non-empty line is one argument,
+// taken literally. A leading "@@" stands for a literal "@", as in
+// "@@home". refs tells where each of the returned arguments comes
+// from.
+func expandArgFiles(args []string) (out []string, refs []argRef, err error) {
+	for _, a := range args {
+		if strings.HasPrefix(a, "@@") {
+			out = append(out, a[1:])
+			refs = append(refs, argRef{})
+			continue
+		}
+		if len(a) < 2 || a[0] != '@' {
+			out = append(out, a)
+			refs = append(refs, argRef{})
+			continue
+		}
+		name := a[1:]
+		r, err := openInput(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		n := 0
+		err = ForEachLine(r, func(line string) error {
+			n++
+			if line != "" {
+				out = append(out, line)
+				refs = append(refs, argRef{name, n})
+			}
+			return nil
+		})
+		r.Close()
+		if err != nil {
+			return nil, nil, newNameError(name, err)
+		}
+	}
+	return out, refs, nil
+}
+
+// locateArgError adds the file and line number to err, which is
+// returned by setField for name, when the offending argument comes
+// from a file.
+func locateArgError(err error, args []string, refs []argRef, i int) error {
+	e, ok := err.(*nameError)
+	if !ok || i >= len(args) || refs[i].file == "" {
+		return err
+	}
+	return newNameError(e.Name, newNameError(refs[i].file, &LineError{refs[i].line, args[i], e.Err}))
+}
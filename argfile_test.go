@@ -0,0 +1,54 @@
+package easy
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestArgFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "easy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	list := writeTemp(t, dir, "list", "b\n\n@c\n")
+	var x struct {
+		Out    string
+		Inputs []string
+	}
+	if err := SetArgs(&x, []string{"@@out", "a", "@" + list, "d"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if x.Out != "@out" || !reflect.DeepEqual(x.Inputs, []string{"a", "b", "@c", "d"}) {
+		t.Errorf("unexpected result %+v", x)
+	}
+	if err := SetArgs(&x, []string{"@" + dir + "/missing"}); err == nil {
+		t.Errorf("expected error")
+	}
+	nums := writeTemp(t, dir, "nums", "1\n2\nthree\n")
+	var y struct {
+		Nums []int
+	}
+	err = SetArgs(&y, []string{"@" + nums})
+	if l, ok := err.(errorList); !ok || len(l) != 1 {
+		t.Errorf("expected a single error; got %v", err)
+	} else if e, ok := l[0].(*nameError); !ok {
+		t.Errorf("expected *nameError; got %v", err)
+	} else if e, ok := e.Err.(*nameError); !ok || e.Name != nums {
+		t.Errorf("expected error in %s; got %v", nums, err)
+	} else if e, ok := e.Err.(*LineError); !ok || e.Num != 3 || e.Line != "three" {
+		t.Errorf("expected error in line 3; got %v", err)
+	}
+}
+
+func TestOpenInputKeepsStdin(t *testing.T) {
+	r, err := openInput("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if _, err := os.Stdin.Stat(); err != nil {
+		t.Errorf("stdin closed: %v", err)
+	}
+}
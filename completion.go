@@ -0,0 +1,298 @@
+package easy
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// flagInfo describes a flag for completion and documentation.
+type flagInfo struct {
+	Name       string
+	Usage      string
+	DefValue   string
+	TakesValue bool
+	Choices    []string // Valid values, when known.
+	Short      string   // One-letter alias, if any.
+}
+
+// argInfo describes a positional argument for completion and
+// documentation.
+type argInfo struct {
+	Name     string
+	Usage    string
+	Choices  []string // Valid values, when known.
+	Optional bool
+	Repeated bool
+}
+
+// flagInfos describes the flags in fs, sorted by name, with the
+// one-letter aliases attached to their flags. fs may be nil.
+func flagInfos(fs *flag.FlagSet) []flagInfo {
+	if fs == nil {
+		return nil
+	}
+	state := stateOf(fs)
+	fields := fieldsByName(state.structs)
+	aliases := map[string]string{}
+	for short, name := range state.shorts {
+		aliases[name] = short
+	}
+	var infos []flagInfo
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, ok := state.shorts[f.Name]; ok || state.hidden[f.Name] {
+			return
+		}
+		info := flagInfo{f.Name, f.Usage, f.DefValue, takesValue(fs, f.Name), nil, aliases[f.Name]}
+		switch v := f.Value.(type) {
+		case *stringChoice:
+			info.Choices = v.Valid
+		case *intChoice:
+			for _, i := range v.Valid {
+				info.Choices = append(info.Choices, strconv.Itoa(i))
+			}
+		}
+		if f, ok := fields[f.Name]; ok {
+			if tag := f.field.Tag.Get("oneof"); tag != "" {
+				info.Choices = strings.Split(tag, "|")
+			}
+		}
+		infos = append(infos, info)
+	})
+	return infos
+}
+
+// argInfos describes the fields of the argument struct ptr, which may
+// be nil.
+func argInfos(ptr interface{}) []argInfo {
+	var infos []argInfo
+	forEachField(ptr, func(name string, field reflect.StructField, _ reflect.Value) error {
+		if field.Tag.Get("hidden") == "true" {
+			return nil
+		}
+		info := argInfo{name, fieldUsage(field), nil, isOptional(field), isSlice(field.Type)}
+		if tag := field.Tag.Get("oneof"); tag != "" {
+			info.Choices = strings.Split(tag, "|")
+		}
+		infos = append(infos, info)
+		return nil
+	})
+	return infos
+}
+
+// commandInfo describes a (sub)command for completion and
+// documentation.
+type commandInfo struct {
+	Name   string // Empty for a program without subcommands.
+	Brief  string
+	Detail string
+	Flags  []flagInfo
+	Args   []argInfo
+}
+
+// commandInfos describes the subcommands in m, sorted by name.
+func commandInfos(m map[string]Cmd) []commandInfo {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cmds := make([]commandInfo, len(names))
+	for i, name := range names {
+		c, d := m[name], describedCmd(name)
+		cmds[i] = commandInfo{name, c.Brief, c.Detail, flagInfos(d.fs), argInfos(d.ptr)}
+	}
+	return cmds
+}
+
+// synopsis returns the positional arguments of c as in FieldNames.
+func (c *commandInfo) synopsis() string {
+	names := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		if a.Repeated {
+			names[i] = "[" + a.Name + " ...]"
+		} else if a.Optional {
+			names[i] = "[" + a.Name + "]"
+		} else {
+			names[i] = a.Name
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// WriteCompletion writes a completion script for shell ("bash", "zsh"
+// or "fish") to w, for program prog with the subcommands in m (see
+// SubCmd). Flags and arguments of a subcommand are completed when they
+// are recorded by DescribeCmd. Flags with known choices (StringChoice,
+// IntChoice or a "oneof" tag) complete to these choices; other flag
+// values and positional arguments complete to file names.
+func WriteCompletion(w io.Writer, shell, prog string, m map[string]Cmd) error {
+	return writeCompletion(w, shell, prog, commandInfos(m))
+}
+
+// WriteCommandCompletion is like WriteCompletion, but for a program
+// without subcommands, whose flags are in fs and whose arguments are
+// set to ptr (see ParseFlagsAndArgsWith). fs and ptr may be nil.
+func WriteCommandCompletion(w io.Writer, shell, prog string, ptr interface{}, fs *flag.FlagSet) error {
+	return writeCompletion(w, shell, prog, []commandInfo{{"", "", "", flagInfos(fs), argInfos(ptr)}})
+}
+
+func writeCompletion(w io.Writer, shell, prog string, cmds []commandInfo) error {
+	prog = filepath.Base(prog)
+	switch shell {
+	case "bash":
+		return writeBashCompletion(w, prog, cmds)
+	case "zsh":
+		// zsh can run bash completion functions after bashcompinit.
+		fmt.Fprintf(w, "autoload -U +X bashcompinit && bashcompinit\n")
+		return writeBashCompletion(w, prog, cmds)
+	case "fish":
+		return writeFishCompletion(w, prog, cmds)
+	}
+	return fmt.Errorf("unsupported shell %q; expected one of bash, zsh or fish", shell)
+}
+
+func writeBashCompletion(w io.Writer, prog string, cmds []commandInfo) error {
+	fn := "_" + shellIdentifier(prog)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "  local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	cmdWord := "\"\""
+	if len(cmds) > 1 || len(cmds) == 1 && cmds[0].Name != "" {
+		var names []string
+		for _, c := range cmds {
+			names = append(names, c.Name)
+		}
+		fmt.Fprintf(w, "  if [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+		fmt.Fprintf(w, "    COMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(names, " ")))
+		fmt.Fprintf(w, "    return\n")
+		fmt.Fprintf(w, "  fi\n")
+		cmdWord = "\"${COMP_WORDS[1]}\""
+	}
+	fmt.Fprintf(w, "  case %s in\n", cmdWord)
+	for _, c := range cmds {
+		fmt.Fprintf(w, "  %s)\n", shellQuote(c.Name))
+		fmt.Fprintf(w, "    case \"$prev\" in\n")
+		var flags []string
+		for _, f := range c.Flags {
+			flags = append(flags, "-"+f.Name)
+			if !f.TakesValue {
+				continue
+			}
+			if f.Short != "" {
+				fmt.Fprintf(w, "    -%s|-%s|--%s)\n", f.Short, f.Name, f.Name)
+			} else {
+				fmt.Fprintf(w, "    -%s|--%s)\n", f.Name, f.Name)
+			}
+			if f.Choices != nil {
+				fmt.Fprintf(w, "      COMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(f.Choices, " ")))
+			} else {
+				fmt.Fprintf(w, "      COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+			}
+			fmt.Fprintf(w, "      return;;\n")
+		}
+		fmt.Fprintf(w, "    esac\n")
+		fmt.Fprintf(w, "    if [[ \"$cur\" == -* ]]; then\n")
+		fmt.Fprintf(w, "      COMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(flags, " ")))
+		fmt.Fprintf(w, "      return\n")
+		fmt.Fprintf(w, "    fi\n")
+		if choices := argChoices(c.Args); choices != nil {
+			fmt.Fprintf(w, "    COMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(choices, " ")))
+		} else {
+			fmt.Fprintf(w, "    COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+		}
+		fmt.Fprintf(w, "    ;;\n")
+	}
+	fmt.Fprintf(w, "  esac\n")
+	fmt.Fprintf(w, "}\n")
+	_, err := fmt.Fprintf(w, "complete -o filenames -F %s %s\n", fn, prog)
+	return err
+}
+
+func writeFishCompletion(w io.Writer, prog string, cmds []commandInfo) error {
+	for _, c := range cmds {
+		cond := ""
+		if c.Name != "" {
+			fmt.Fprintf(w, "complete -c %s -f -n '__fish_use_subcommand' -a %s -d %s\n", prog, fishQuote(c.Name), fishQuote(c.Brief))
+			cond = " -n " + fishQuote("__fish_seen_subcommand_from "+c.Name)
+		}
+		for _, f := range c.Flags {
+			fmt.Fprintf(w, "complete -c %s%s -o %s", prog, cond, fishQuote(f.Name))
+			if f.Short != "" {
+				fmt.Fprintf(w, " -s %s", fishQuote(f.Short))
+			}
+			if f.TakesValue {
+				if f.Choices != nil {
+					fmt.Fprintf(w, " -x -a %s", fishQuote(strings.Join(f.Choices, " ")))
+				} else {
+					fmt.Fprintf(w, " -r")
+				}
+			}
+			fmt.Fprintf(w, " -d %s\n", fishQuote(firstLine(f.Usage)))
+		}
+		if choices := argChoices(c.Args); choices != nil {
+			fmt.Fprintf(w, "complete -c %s%s -f -a %s\n", prog, cond, fishQuote(strings.Join(choices, " ")))
+		}
+	}
+	return nil
+}
+
+// argChoices returns the choices of the first positional argument that
+// has any, or nil; shells complete all positional arguments alike.
+func argChoices(args []argInfo) []string {
+	for _, a := range args {
+		if a.Choices != nil {
+			return a.Choices
+		}
+	}
+	return nil
+}
+
+func shellIdentifier(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return "'" + strings.Replace(s, "'", `\'`, -1) + "'"
+}
+
+func firstLine(s string) string {
+	if i := strings.Index(s, "\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func newCompletion(prog string, m map[string]Cmd) Cmd {
+	return Cmd{
+		Brief:  "prints a shell completion script",
+		Detail: `"completion <shell>" prints a completion script for shell, which is one of bash, zsh or fish, to stdout. For example, run "source <(` + filepath.Base(prog) + ` completion bash)" in bash.`,
+		Action: func(args []string) {
+			if len(args) != 1 {
+				fmt.Fprintln(os.Stderr, "completion: expected exactly one argument: bash, zsh or fish")
+				Exit(1)
+			}
+			if err := WriteCompletion(os.Stdout, args[0], prog, m); err != nil {
+				fmt.Fprintln(os.Stderr, "completion:", err)
+				Exit(1)
+			}
+		},
+	}
+}
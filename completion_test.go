@@ -0,0 +1,48 @@
+package easy
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestWriteCompletion(t *testing.T) {
+	var flags struct {
+		Mode    string `oneof:"fast|slow" short:"m"`
+		Verbose bool
+	}
+	fs := flag.NewFlagSet("run", 0)
+	AddFlags(&flags, fs)
+	var args struct {
+		Input string
+	}
+	DescribeCmd("run", fs, &args)
+	defer DescribeCmd("run", nil, nil)
+	m := map[string]Cmd{
+		"run":  {Brief: "runs it"},
+		"list": {Brief: "lists them"},
+	}
+	for _, c := range []struct {
+		shell    string
+		expected []string
+	}{
+		{"bash", []string{"compgen -W 'list run'", "-m|-mode|--mode)", "'fast slow'", "'-mode -verbose'", "complete -o filenames -F _my_prog my-prog"}},
+		{"zsh", []string{"bashcompinit", "complete -o filenames -F _my_prog my-prog"}},
+		{"fish", []string{"-a 'run' -d 'runs it'", "-n '__fish_seen_subcommand_from run' -o 'mode' -s 'm' -x -a 'fast slow'", "-o 'verbose' -d ''"}},
+	} {
+		var b bytes.Buffer
+		if err := WriteCompletion(&b, c.shell, "/bin/my-prog", m); err != nil {
+			t.Errorf("%s: unexpected error: %v", c.shell, err)
+			continue
+		}
+		for _, s := range c.expected {
+			if !strings.Contains(b.String(), s) {
+				t.Errorf("%s: expected %q in:\n%s", c.shell, s, b.String())
+			}
+		}
+	}
+	if err := WriteCompletion(&bytes.Buffer{}, "csh", "prog", m); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -1,6 +1,7 @@
 package easy
 
 import (
+	"encoding"
 	"errors"
 	"flag"
 	"fmt"
@@ -8,133 +9,634 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 	"unsafe"
-
-	"github.com/golang/glog"
 )
 
 // Init does the common initialization needed in a command tool. ptr
-// is a pointer to an argument struct (see ParseFlagsAndArgs()).
+// is a pointer to an argument struct (see ParseFlagsAndArgs()). Init
+// also adds flag -config for loading flags from a config file (see
+// AddConfigFlag) unless there is already such a flag, and flag
+// -dump_config for writing the effective configuration to a file
+// (see WriteSettings). Flag -logger selects Log by name (std, glog or,
+// since Go 1.21, slog); it defaults to glog unless Log is already set
+// by the program. glog then logs to stderr unless -logtostderr is
+// given. Messages logged while parsing go to the selected Logger.
+//
+// Init also adds flags -cpuprofile, -memprofile, -blockprofile,
+// -mutexprofile and -trace (except those already defined), which
+// write the respective profiles or execution trace to the named
+// files. They start after parsing and are written by Cleanup, so call
+// "defer easy.Cleanup()" in main after Init. See also InitContext.
 func Init(ptr interface{}) {
 	command := strings.Join(os.Args, " ")
+	backend := &stringChoice{loggerNames(), ""}
+	if Log == nil {
+		backend.Value = "glog"
+	}
+	if flag.Lookup("logger") == nil {
+		flag.Var(backend, "logger", fmt.Sprintf("where to write logs (one of %q)", backend.Valid))
+	}
+	if flag.Lookup("config") == nil {
+		AddConfigFlag(flag.CommandLine)
+	}
+	dump := new(string)
+	if flag.Lookup("dump_config") == nil {
+		flag.StringVar(dump, "dump_config", "", "write the effective configuration as JSON to this file")
+	}
+	profiles := addProfileFlags(flag.CommandLine)
+	// Hold the messages logged while parsing (e.g. about deprecated
+	// flags) until -logger is known; they are also written when
+	// parsing fails.
+	pending := &bufferedLogger{fallback: Log}
+	Log = pending
+	AtExit(pending.flush)
+	ParseFlagsAndArgs(ptr)
+	if backend.Value != "" {
+		Log = loggers[backend.Value]()
+	}
 	// When using glog, I would like to log to stderr by default.
-	if f := flag.Lookup("logtostderr"); f != nil {
+	if f := flag.Lookup("logtostderr"); f != nil && backend.Value == "glog" && stateOf(flag.CommandLine).origins[f.Name] == "" {
 		f.Value.Set("true")
-		f.DefValue = "true"
 	}
-	ParseFlagsAndArgs(ptr)
-	glog.Info("Command: ", command)
+	pending.flush()
+	if err := profiles.start(); err != nil {
+		logger().Fatal("profiling: ", err)
+	}
+	logger().Info("Command: ", command)
+	if *dump != "" {
+		if err := writeSettingsFile(*dump, flag.CommandLine, ptr); err != nil {
+			logger().Fatal("dump_config: ", err)
+		}
+	}
 }
 
 // AddFlags adds flags to fs from ptr. ptr must be a pointer to a
 // struct type. Every exported field in the struct is added as a
-// flag. Only built-in flag variable types except Duration (bool,
-// float64, int64, int, string, uint64, uint) are supported. The name
-// (or usage, respectively) of a field can be customized with a field
-// tag named "name" (or "usage", respectively).
+// flag. Supported types are the built-in flag variable types (bool,
+// time.Duration, float64, int64, int, string, uint64, uint) and any
+// type whose pointer implements flag.Value or
+// encoding.TextUnmarshaler, as well as types registered by
+// RegisterType. The name (or usage, respectively) of a
+// field can be customized with a field tag named "name" (or "usage",
+// respectively).
+//
+// A slice field (e.g. []string) is a repeatable flag, as in -x a -x
+// b; a map field (e.g. map[string]int) is a repeatable flag of
+// key=value pairs, as in -label a=1 -label b=2. Values given on the
+// command line replace the default elements. With a "sep" tag
+// (e.g. sep:","), each value may also contain multiple elements
+// separated by sep.
+//
+// A field of struct type (other than the supported types above) is a
+// group of flags: each of its fields is added with the group name and
+// a dot as prefix (e.g. field DB with field Host becomes flag
+// -db.host). Fields of anonymous embedded structs are added as if
+// they were fields of the embedding struct, unless the embedded field
+// has a "name" tag.
+//
+// An integer field with a "unit" tag (or any integer field when
+// HumanNumbers is set) accepts human-friendly numbers: either with a
+// radix prefix (0x1f, 0o755 or 0b101), or a decimal number followed by
+// an optional suffix, k (or K), M, G, T, P or E for powers of 1000, or
+// Ki, Mi, Gi, Ti, Pi or Ei for powers of 1024, as in 10k or 1.5G;
+// underscores between digits are ignored. With unit:"bytes", the
+// number may end with B, as in 64MiB. See also ByteSize and Count.
+//
+// A pointer field (e.g. *int) is left nil unless the flag is given,
+// so that it tells whether the flag is given at all (see also
+// Specified). Pointers to groups of fields (structs) are not
+// supported.
+//
+// A field with tag "env" takes its value from the named environment
+// variable (prefixed by EnvPrefix) when the flag is not given on the
+// command line. This only happens when fs is parsed by
+// ParseFlagsAndArgs or ParseFlagsAndArgsWith.
+//
+// A field with tag "short" (e.g. short:"o") also gets a one-letter
+// alias, which is mostly useful with GNUFlags (see SetParseMode).
+//
+// A field with tag hidden:"true" is accepted but not shown in the
+// usage printed by ParseFlagsAndArgs and ParseFlagsAndArgsWith. A
+// field with tag "deprecated" (e.g. deprecated:"use -foo instead") is
+// accepted, but a warning with the tag is logged (see Log) when it is
+// set on the command line, in a config file or from the environment;
+// with tag "replacement" (e.g. replacement:"foo"), its values are also
+// forwarded to the named flag.
+//
+// Flags of fields with a "group" tag are listed under a heading named
+// by the tag in the usage (see WriteFlags).
+//
+// Tags "exclusive", "requires" and "atleastone" declare constraints
+// among flags (see Exclusive, Requires and AtLeastOne).
 func AddFlags(ptr interface{}, fs *flag.FlagSet) {
-	forEachField(ptr, func(field reflect.StructField, value reflect.Value) error {
-		if field.PkgPath == "" {
-			addFieldFlag(field, value, fs)
+	forEachField(ptr, func(name string, field reflect.StructField, value reflect.Value) error {
+		addFieldFlag(name, field, value, fs)
+		checkTags(name, field)
+		if field.Tag.Get("hidden") == "true" {
+			updateState(fs, func(s *flagSetState) {
+				s.hidden[name] = true
+			})
 		}
+		if msg := field.Tag.Get("deprecated"); msg != "" {
+			f := fs.Lookup(name)
+			f.Value = &deprecatedValue{f.Value, fs, name, msg, field.Tag.Get("replacement"), false}
+		}
+		if short := field.Tag.Get("short"); short != "" {
+			addShortFlag(short, name, fs)
+		}
+		addTagConstraints(fs, name, field.Tag)
+		return nil
+	})
+	defaults := copyStruct(ptr)
+	updateState(fs, func(s *flagSetState) {
+		s.structs = append(s.structs, ptr)
+		s.defaults = append(s.defaults, defaults)
+	})
+}
+
+// EnvPrefix is prepended to the variable names given by "env" field
+// tags.
+var EnvPrefix string
+
+// deprecatedValue warns about a deprecated flag and optionally
+// forwards its values to a replacement flag.
+type deprecatedValue struct {
+	flag.Value
+	fs          *flag.FlagSet
+	name        string
+	msg         string
+	replacement string
+	warned      bool
+}
+
+func (d *deprecatedValue) Set(s string) error {
+	if !d.warned {
+		logger().Warning("-", d.name, " is deprecated: ", d.msg)
+		d.warned = true
+	}
+	if err := d.Value.Set(s); err != nil {
+		return err
+	}
+	if d.replacement != "" {
+		return d.fs.Set(d.replacement, s)
+	}
+	return nil
+}
+
+// deprecate warns about the deprecated field of flag name, if it is
+// deprecated, when it is set from origin (a config file or the
+// environment; see deprecatedValue for the command line). Its
+// replacement among fields, if any, is then set as well by set,
+// unless it is already given from the command line or origin.
+func deprecate(name string, field reflect.StructField, fields map[string]namedField, origins map[string]string, origin string, set func(replacement namedField) error) error {
+	msg := field.Tag.Get("deprecated")
+	if msg == "" {
 		return nil
+	}
+	logger().Warning("-", name, " is deprecated: ", msg)
+	replacement := field.Tag.Get("replacement")
+	f, ok := fields[replacement]
+	if !ok || origins[replacement] == fromFlag || origins[replacement] == origin {
+		return nil
+	}
+	origins[replacement] = origin
+	return newNameError(replacement, set(f))
+}
+
+func (d *deprecatedValue) IsBoolFlag() bool {
+	b, ok := d.Value.(interface {
+		IsBoolFlag() bool
 	})
+	return ok && b.IsBoolFlag()
+}
+
+// printDefaults is like fs.PrintDefaults, but in the layout of
+// WriteFlags.
+func printDefaults(fs *flag.FlagSet) {
+	WriteFlags(fs.Output(), fs)
+}
+
+func addShortFlag(short, name string, fs *flag.FlagSet) {
+	if len(short) != 1 {
+		panic(fmt.Sprintf("short name of -%s must be a single letter; got %q", name, short))
+	}
+	fs.Var(fs.Lookup(name).Value, short, "short for -"+name)
+	updateState(fs, func(s *flagSetState) {
+		s.shorts[short] = name
+	})
+}
+
+// ParseMode controls how ParseFlagsAndArgs and ParseFlagsAndArgsWith
+// parse the command line, in addition to what package flag does.
+type ParseMode int
+
+const (
+	// GNUFlags parses flags in the style of GNU getopt_long: long
+	// flags are written as --name=value or --name value; one-letter
+	// flags (see the "short" tag of AddFlags) are written as -o value,
+	// -ovalue or -o=value, and boolean ones can be bundled as in -vq;
+	// "--" terminates the flags. For compatibility, -name is still
+	// accepted when it exactly matches a long flag.
+	GNUFlags ParseMode = 1 << iota
+	// Interspersed allows flags to appear anywhere among the
+	// positional arguments, until "--". Arguments starting with "-"
+	// (except "-" itself) are then always taken as flags, so use "--"
+	// to pass them as positional arguments.
+	Interspersed
+)
+
+// SetParseMode sets the parse mode of fs, which is used when fs is
+// parsed by ParseFlagsAndArgs or ParseFlagsAndArgsWith. Use
+// flag.CommandLine for ParseFlagsAndArgs.
+func SetParseMode(fs *flag.FlagSet, mode ParseMode) {
+	updateState(fs, func(s *flagSetState) {
+		s.mode = mode
+	})
+}
+
+// normalizeArgs rewrites args according to the parse mode of fs, so
+// that the result can be parsed by package flag.
+func normalizeArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	mode := stateOf(fs).mode
+	if mode&GNUFlags != 0 {
+		var err error
+		if args, err = gnuArgs(fs, args, mode&Interspersed != 0); err != nil {
+			return nil, err
+		}
+	}
+	if mode&Interspersed != 0 {
+		args = permuteArgs(fs, args)
+	}
+	return args, nil
+}
+
+// permuteArgs moves all the flags in args (and their values) before
+// the positional arguments, which are separated from the flags by
+// "--" when there are any.
+func permuteArgs(fs *flag.FlagSet, args []string) []string {
+	var flags, positional []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		name, _, hasValue, ok := splitFlag(args[i])
+		if !ok {
+			positional = append(positional, args[i])
+			continue
+		}
+		flags = append(flags, args[i])
+		if !hasValue && takesValue(fs, name) && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+	}
+	if len(positional) == 0 {
+		return flags
+	}
+	return append(append(flags, "--"), positional...)
+}
+
+// gnuArgs rewrites GNU style flags in args as understood by package
+// flag, i.e. -name=value or -name for boolean flags, where name is the
+// full flag name. When interspersed is true, flags after positional
+// arguments are rewritten as well.
+func gnuArgs(fs *flag.FlagSet, args []string, interspersed bool) ([]string, error) {
+	shorts := stateOf(fs).shorts
+	var out []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			return append(out, args[i:]...), nil
+		}
+		if len(a) < 2 || a[0] != '-' {
+			if !interspersed {
+				return append(out, args[i:]...), nil
+			}
+			out = append(out, a)
+			continue
+		}
+		if name, _, hasValue, ok := splitFlag(a); ok && (a[1] == '-' || len(name) > 1 && fs.Lookup(name) != nil) {
+			// A long flag.
+			out = append(out, "-"+strings.TrimLeft(a, "-"))
+			if !hasValue && takesValue(fs, name) && i+1 < len(args) {
+				i++
+				out = append(out, args[i])
+			}
+			continue
+		}
+		// Bundled one-letter flags.
+		body := a[1:]
+		for j := 0; j < len(body); j++ {
+			short := body[j : j+1]
+			name, ok := shorts[short]
+			if !ok {
+				name = short
+			}
+			if fs.Lookup(name) == nil {
+				return nil, fmt.Errorf("flag provided but not defined: -%s (in %s)", short, a)
+			}
+			rest := body[j+1:]
+			if strings.HasPrefix(rest, "=") {
+				out = append(out, "-"+name+rest)
+				break
+			}
+			if !takesValue(fs, name) {
+				out = append(out, "-"+name)
+				continue
+			}
+			if rest == "" {
+				if i+1 == len(args) {
+					return nil, fmt.Errorf("flag needs an argument: -%s", short)
+				}
+				i++
+				rest = args[i]
+			}
+			out = append(out, "-"+name+"="+rest)
+			break
+		}
+	}
+	return out, nil
 }
 
 // SetArgs sets the given ptr from command line arguments. ptr must be
 // a pointer to a struct type. Every exported field is processed in
-// the order of declaration. Only bool, float64, int64, int, string,
-// uint64, uint and slices of these types can be set. The name (or
-// usage, respectively) of a field can be customized with a field tag
-// named "name" (or "usage", respectively).
+// the order of declaration. Only the types supported by AddFlags
+// (including pointers) and slices of these types can be set. The name
+// (or usage, respectively) of a field can be customized with a field
+// tag named "name" (or "usage", respectively). Struct fields are
+// grouped in the same way as AddFlags. A missing argument is taken
+// from the environment variable named by tag "env", when there is
+// one.
+//
+// All the errors are returned together, one per line.
+//
+// A field with tag optional:"true" or a "default" tag is optional:
+// when its argument is missing, it is set from the "default" tag if
+// there is one, or otherwise left unchanged. Optional fields (and
+// slices) must come after all the required ones.
+//
+// An argument @path is replaced by the lines of file path (read by
+// Open), one argument per non-empty line, which helps with long lists
+// of arguments. Write @@ for an argument that starts with a literal @.
 func SetArgs(ptr interface{}, args []string) error {
+	_, err := setArgs(ptr, args)
+	return err
+}
+
+// setArgs is SetArgs, which also returns the origins of the fields.
+func setArgs(ptr interface{}, args []string) (map[string]string, error) {
+	args, refs, err := expandArgFiles(args)
+	if err != nil {
+		return nil, err
+	}
 	i := 0
-	if err := forEachField(ptr, func(field reflect.StructField, value reflect.Value) error {
-		if field.PkgPath == "" {
-			n, err := setField(field, value, args[i:])
-			if err != nil {
-				return err
-			}
-			i += n
+	optional := ""
+	origins := map[string]string{}
+	var errs errorList
+	unset := map[string]bool{} // Failed, or left to the zero value.
+	forEachField(ptr, func(name string, field reflect.StructField, value reflect.Value) error {
+		if isOptional(field) || isSlice(field.Type) {
+			optional = name
+		} else if optional != "" {
+			panic(fmt.Sprintf("required argument %s after optional argument %s", name, optional))
 		}
+		checkTags(name, field)
+		n, origin, err := setField(name, field, value, args[i:])
+		if err == nil {
+			origins[name] = origin
+		} else if isSlice(field.Type) {
+			// setSlice stops at the offending argument, but the rest
+			// still belongs to the slice.
+			errs.add(locateArgError(err, args, refs, i+n))
+			n = len(args) - i
+		} else {
+			errs.add(locateArgError(err, args, refs, i))
+		}
+		if _, ok := field.Tag.Lookup("default"); err != nil || origin == fromDefault && !ok {
+			unset[name] = true
+		}
+		i += n
 		return nil
-	}); err != nil {
-		return err
-	}
+	})
 	if i != len(args) {
-		return errors.New("extra arguments: " + fmt.Sprintf("%q", args[i:]))
+		errs.add(errors.New("extra arguments: " + fmt.Sprintf("%q", args[i:])))
 	}
-	return nil
+	// Do not validate the fields that are not set.
+	if l, ok := validate(ptr, nil).(errorList); ok {
+		for _, err := range l {
+			if e, ok := err.(*nameError); !ok || !unset[e.Name] {
+				errs.add(err)
+			}
+		}
+	}
+	return origins, errs.err()
 }
 
 // ParseFlagsAndArgs parses the standard flags and then sets the
 // arguments to ptr. When ptr is nil, flags are still parsed but no
 // arguments will be procssed. This does certain magic with flags as
-// well (e.g. tweaking glog).
+// well (e.g. tweaking glog). There is a built-in flag -flagfile=path
+// that reads more flags from path, one per line, in place of itself;
+// it can be repeated and flag files may include others. Positional
+// arguments may also be read from files (see SetArgs). See
+// SetParseMode for GNU style flags and flags interspersed with
+// arguments.
 func ParseFlagsAndArgs(ptr interface{}) {
 	flag.Usage = func() {
-		CombinedUsage(os.Args[0], ptr, flag.PrintDefaults)
-	}
-	flag.Parse()
-	if err := SetArgs(ptr, flag.Args()); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		flag.Usage()
-		os.Exit(2)
+		WriteUsage(flag.CommandLine.Output(), os.Args[0], ptr, flag.CommandLine)
 	}
+	handleParseError(flag.CommandLine, parseFlagsAndArgs(ptr, flag.CommandLine, os.Args[1:]))
 }
 
 // ParseFlagsAndArgsWith parses a specified flagset and the sets the
 // arguments to ptr. The flagset may be nil, in which case no flags
-// except -h are processed. ptr may also be nil, in which case no
-// arguments will be processed.
+// except -h and -flagfile are processed, and nothing is recorded for
+// Settings and Specified. ptr may also be nil, in which case no
+// arguments will be processed. See ParseFlagsAndArgs for -flagfile.
+// State about fs is kept until Release.
+//
+// Parsing goes on after errors: all the errors in flags and arguments
+// (with suggestions for misspelled flags) are printed together,
+// followed by the usage, to the output of fs. The error is then
+// handled as fs.Parse would, according to the error handling of fs.
+// Note that, like fs.Parse but unlike earlier versions of this
+// function, the errors and usage are printed even when fs uses
+// flag.ContinueOnError; call fs.SetOutput(ioutil.Discard) to silence
+// them. The returned error lists the errors one per line, and
+// unwraps to them (see errors.Is and errors.As).
 func ParseFlagsAndArgsWith(name string, ptr interface{}, fs *flag.FlagSet, args []string) error {
 	if fs == nil {
 		fs = flag.NewFlagSet("", 0)
+		defer Release(fs)
 	}
 	fs.Usage = func() {
-		CombinedUsage(name, ptr, fs.PrintDefaults)
+		WriteUsage(fs.Output(), name, ptr, fs)
 	}
-	if err := fs.Parse(args); err != nil {
-		return err
+	return handleParseError(fs, parseFlagsAndArgs(ptr, fs, args))
+}
+
+// handleParseError prints err, followed by the usage of fs unless it
+// is flag.ErrHelp (whose usage is already printed), and then exits,
+// panics or returns err according to the error handling of fs.
+func handleParseError(fs *flag.FlagSet, err error) error {
+	if err == nil {
+		return nil
+	}
+	if err != flag.ErrHelp {
+		fmt.Fprintln(fs.Output(), err)
+		fs.Usage()
+	}
+	switch fs.ErrorHandling() {
+	case flag.ExitOnError:
+		if err == flag.ErrHelp {
+			Exit(0)
+		}
+		Exit(2)
+	case flag.PanicOnError:
+		panic(err)
 	}
-	return SetArgs(ptr, fs.Args())
+	return err
 }
 
-func CombinedUsage(name string, ptr interface{}, printDefaults func()) {
-	fmt.Fprintf(os.Stderr, "Usage: %s [flags] %s\n", name, strings.Join(FieldNames(ptr), " "))
+func parseFlagsAndArgs(ptr interface{}, fs *flag.FlagSet, args []string) error {
+	invalid, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	var errs errorList
+	errs.add(invalid)
+	origins, err := setArgs(ptr, fs.Args())
+	errs.add(err)
 	if ptr != nil {
-		fmt.Fprintf(os.Stderr, "\nArguments:\n")
-		PrintArguments(ptr)
+		updateState(fs, func(s *flagSetState) {
+			s.args = ptr
+			s.argOrigins = origins
+		})
+	}
+	return errs.err()
+}
+
+// parseFlags parses the flags in args into fs, including flag files,
+// the config file and the environment, and then validates them. err
+// is an error that stops parsing; invalid lists the other errors.
+func parseFlags(fs *flag.FlagSet, args []string) (invalid, err error) {
+	addFlagFileFlag(fs)
+	if args, err = normalizeArgs(fs, args); err != nil {
+		return nil, err
+	}
+	if args, err = expandFlagFiles(fs, args); err != nil {
+		return nil, err
+	}
+	if err = parseArgs(fs, args); err == flag.ErrHelp {
+		return nil, err
+	}
+	var errs errorList
+	errs.add(err)
+	state := stateOf(fs)
+	origins := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		if name, ok := state.shorts[f.Name]; ok {
+			origins[name] = fromFlag
+		} else {
+			origins[f.Name] = fromFlag
+		}
+	})
+	// The config file goes first so that the environment overrides it.
+	if name := state.configFile; name != nil && *name != "" {
+		errs.add(loadConfig(*name, state.structs, origins))
+	}
+	errs.add(setFlagsFromEnv(fs, origins))
+	updateState(fs, func(s *flagSetState) {
+		s.origins = origins
+	})
+	for _, ptr := range state.structs {
+		errs.add(validate(ptr, origins))
 	}
+	errs.add(checkConstraints(fs, origins))
+	return errs.err(), nil
+}
+
+// Origins of values (see Setting).
+const (
+	fromDefault = "default"
+	fromFlag    = "flag"
+	fromArg     = "positional"
+	fromEnv     = "env"
+	fromFile    = "file"
+)
+
+// setFlagsFromEnv sets the flags added by AddFlags to fs but not
+// given on the command line from the environment. origins is updated
+// accordingly.
+func setFlagsFromEnv(fs *flag.FlagSet, origins map[string]string) error {
+	structs := stateOf(fs).structs
+	fields := fieldsByName(structs)
+	for _, ptr := range structs {
+		if err := forEachField(ptr, func(name string, field reflect.StructField, value reflect.Value) error {
+			if origins[name] == fromFlag {
+				return nil
+			}
+			ok, err := setFromEnv(name, field, value)
+			if !ok {
+				return nil
+			}
+			origins[name] = fromEnv
+			if err != nil {
+				return err
+			}
+			s := os.Getenv(envName(field))
+			return deprecate(name, field, fields, origins, fromEnv, func(r namedField) error {
+				return setEnvValue(r.field, r.value, s)
+			})
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CombinedUsage prints the usage of program name to stderr, like
+// WriteUsage, but with the flags printed by printDefaults, or by
+// WriteFlags for flag.CommandLine when printDefaults is nil.
+func CombinedUsage(name string, ptr interface{}, printDefaults func()) {
+	WriteUsage(os.Stderr, name, ptr, nil)
 	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	if printDefaults == nil {
+		WriteFlags(os.Stderr, flag.CommandLine)
+		return
+	}
 	printDefaults()
 }
 
 func FieldNames(ptr interface{}) (s []string) {
-	forEachField(ptr, func(field reflect.StructField, _ reflect.Value) error {
-		if field.PkgPath == "" {
-			name, _ := getFieldNameUsage(field)
-			if field.Type.Kind() == reflect.Slice {
-				name = "[" + name + " ...]"
-			}
-			s = append(s, name)
+	forEachField(ptr, func(name string, field reflect.StructField, _ reflect.Value) error {
+		if field.Tag.Get("hidden") == "true" {
+			return nil
 		}
+		if isSlice(field.Type) {
+			name = "[" + name + " ...]"
+		} else if isOptional(field) {
+			name = "[" + name + "]"
+		}
+		s = append(s, name)
 		return nil
 	})
 	return
 }
 
+// PrintArguments prints the usage of the positional arguments in ptr
+// to stderr (see WriteArguments).
 func PrintArguments(ptr interface{}) {
-	forEachField(ptr, func(field reflect.StructField, _ reflect.Value) error {
-		if field.PkgPath == "" {
-			name, usage := getFieldNameUsage(field)
-			fmt.Fprintf(os.Stderr, "  %s: %s\n", name, usage)
-		}
-		return nil
-	})
+	WriteArguments(os.Stderr, ptr)
 }
 
-func forEachField(ptr interface{}, action func(reflect.StructField, reflect.Value) error) error {
+// forEachField calls action on every exported field of the struct
+// pointed by ptr, in the order of declaration, with the full name of
+// the field. Fields of nested structs are visited recursively (see
+// AddFlags).
+func forEachField(ptr interface{}, action func(string, reflect.StructField, reflect.Value) error) error {
 	if ptr == nil {
 		return nil
 	}
@@ -146,20 +648,67 @@ func forEachField(ptr interface{}, action func(reflect.StructField, reflect.Valu
 	if elemValue.Kind() != reflect.Struct {
 		panic(fmt.Sprintf("required pointer to struct; got %v", ptrValue))
 	}
+	return forEachNestedField("", elemValue, action)
+}
+
+func forEachNestedField(prefix string, elemValue reflect.Value, action func(string, reflect.StructField, reflect.Value) error) error {
 	elemType := elemValue.Type()
 	for i := 0; i < elemType.NumField(); i++ {
 		field := elemType.Field(i)
 		value := elemValue.Field(i)
-		if err := action(field, value); err != nil {
+		if isGroup(field.Type) {
+			// Embedded structs are flattened, even when the type itself
+			// is not exported (as in encoding/json).
+			if field.Anonymous && field.Tag.Get("name") == "" {
+				if err := forEachNestedField(prefix, value, action); err != nil {
+					return err
+				}
+			} else if field.PkgPath == "" {
+				name, _ := getFieldNameUsage(field)
+				if err := forEachNestedField(prefix+name+".", value, action); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		name, _ := getFieldNameUsage(field)
+		if err := action(prefix+name, field, value); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func addFieldFlag(field reflect.StructField, value reflect.Value, fs *flag.FlagSet) {
-	name, usage := getFieldNameUsage(field)
+func addFieldFlag(name string, field reflect.StructField, value reflect.Value, fs *flag.FlagSet) {
+	usage := fieldUsage(field)
+	if v := valueOf(value); v != nil {
+		fs.Var(v, name, usage)
+		return
+	}
+	if v := newCollectionValue(field, value); v != nil {
+		fs.Var(v, name, usage)
+		return
+	}
+	if field.Type.Kind() == reflect.Ptr {
+		if isGroup(field.Type.Elem()) {
+			// Groups are not allocated on demand.
+			panic(fmt.Sprintf("unsupported type: %v (use %v to group flags)", field.Type, field.Type.Elem()))
+		}
+		fs.Var(&pointerValue{field, value}, name, usage)
+		return
+	}
+	if _, ok := field.Tag.Lookup("unit"); (ok || HumanNumbers) && isInteger(field.Type) {
+		fs.Var(&numberValue{field, value}, name, usage)
+		return
+	}
 	ptr := unsafe.Pointer(value.UnsafeAddr())
+	if field.Type == durationType {
+		fs.DurationVar((*time.Duration)(ptr), name, *(*time.Duration)(ptr), usage)
+		return
+	}
 	switch field.Type.Kind() {
 	case reflect.Bool:
 		fs.BoolVar((*bool)(ptr), name, *(*bool)(ptr), usage)
@@ -180,6 +729,91 @@ func addFieldFlag(field reflect.StructField, value reflect.Value, fs *flag.FlagS
 	}
 }
 
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	flagValueType       = reflect.TypeOf((*flag.Value)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isSlice tells whether a field of type t takes a variable number of
+// positional arguments. Slice types that handle parsing by themselves
+// (e.g. Strings) take exactly one argument.
+func isSlice(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice || isRegistered(t) {
+		return false
+	}
+	p := reflect.PtrTo(t)
+	return !p.Implements(flagValueType) && !p.Implements(textUnmarshalerType)
+}
+
+// isGroup tells whether a field of type t is a group of fields
+// instead of a single value.
+func isGroup(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || isRegistered(t) {
+		return false
+	}
+	p := reflect.PtrTo(t)
+	return !p.Implements(flagValueType) && !p.Implements(textUnmarshalerType)
+}
+
+// valueOf returns a flag.Value that sets value in place when the type
+// of value is registered by RegisterType, or the pointer to value
+// implements either flag.Value or encoding.TextUnmarshaler; otherwise
+// it returns nil.
+func valueOf(value reflect.Value) flag.Value {
+	if v := newRegisteredValue(value); v != nil {
+		return v
+	}
+	p := settable(value).Addr().Interface()
+	switch v := p.(type) {
+	case flag.Value:
+		return v
+	case encoding.TextUnmarshaler:
+		return &textValue{v}
+	}
+	return nil
+}
+
+// formatValue formats value as it would be given on the command line.
+// A nil pointer is formatted as "".
+func formatValue(value reflect.Value) string {
+	if v := valueOf(value); v != nil {
+		return v.String()
+	}
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return ""
+		}
+		return formatValue(value.Elem())
+	}
+	return fmt.Sprint(settable(value).Interface())
+}
+
+// textValue adapts an encoding.TextUnmarshaler to flag.Value. When
+// the underlying value also implements encoding.TextMarshaler, it is
+// used for printing the value.
+type textValue struct {
+	p encoding.TextUnmarshaler
+}
+
+func (t *textValue) String() string {
+	if t.p == nil {
+		return ""
+	}
+	if m, ok := t.p.(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+	return fmt.Sprint(reflect.ValueOf(t.p).Elem().Interface())
+}
+
+func (t *textValue) Set(s string) error {
+	return t.p.UnmarshalText([]byte(s))
+}
+
 func getFieldNameUsage(field reflect.StructField) (name, usage string) {
 	name = field.Tag.Get("name")
 	if name == "" {
@@ -189,6 +823,82 @@ func getFieldNameUsage(field reflect.StructField) (name, usage string) {
 	return
 }
 
+// namedField is a field found by forEachField.
+type namedField struct {
+	field reflect.StructField
+	value reflect.Value
+}
+
+// fieldsByName maps the full names of the fields of ptrs to the
+// fields.
+func fieldsByName(ptrs []interface{}) map[string]namedField {
+	fields := map[string]namedField{}
+	for _, ptr := range ptrs {
+		forEachField(ptr, func(name string, field reflect.StructField, value reflect.Value) error {
+			fields[name] = namedField{field, value}
+			return nil
+		})
+	}
+	return fields
+}
+
+// fieldUsage returns the usage of field, annotated with information
+// from other field tags.
+func fieldUsage(field reflect.StructField) string {
+	_, usage := getFieldNameUsage(field)
+	if note := validationUsage(field); note != "" {
+		usage = appendUsage(usage, note)
+	}
+	if env := envName(field); env != "" {
+		usage = appendUsage(usage, "(env $"+env+")")
+	}
+	if msg := field.Tag.Get("deprecated"); msg != "" {
+		usage = appendUsage(usage, "(deprecated: "+msg+")")
+	}
+	return usage
+}
+
+func appendUsage(usage, note string) string {
+	if usage == "" {
+		return note
+	}
+	return usage + " " + note
+}
+
+// envName returns the name of the environment variable for field, or
+// "" when field does not have an "env" tag.
+func envName(field reflect.StructField) string {
+	if env := field.Tag.Get("env"); env != "" {
+		return EnvPrefix + env
+	}
+	return ""
+}
+
+// setFromEnv sets value from the environment variable of field, if
+// the variable is set. ok tells whether the variable is set.
+func setFromEnv(name string, field reflect.StructField, value reflect.Value) (ok bool, err error) {
+	env := envName(field)
+	if env == "" {
+		return false, nil
+	}
+	s, ok := os.LookupEnv(env)
+	if !ok {
+		return false, nil
+	}
+	if err := setEnvValue(field, value, s); err != nil {
+		return true, newNameError(name, fmt.Errorf("$%s: %v", env, err))
+	}
+	return true, nil
+}
+
+// setEnvValue sets a field to s from an environment variable.
+func setEnvValue(field reflect.StructField, value reflect.Value, s string) error {
+	if v := newCollectionValue(field, value); v != nil {
+		return v.Set(s)
+	}
+	return setFieldValue(field, value, s)
+}
+
 type nameError struct {
 	Name string
 	Err  error
@@ -205,20 +915,87 @@ func newNameError(name string, err error) error {
 	return &nameError{name, err}
 }
 
-func setField(field reflect.StructField, value reflect.Value, args []string) (int, error) {
-	name, _ := getFieldNameUsage(field)
-	if field.Type.Kind() == reflect.Slice {
-		n, err := setSlice(value, args)
-		return n, newNameError(name, err)
+// errorList collects multiple errors, one per line.
+type errorList []error
+
+func (l errorList) Error() string {
+	s := make([]string, len(l))
+	for i, err := range l {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+// Unwrap returns the errors in l, for errors.Is and errors.As.
+func (l errorList) Unwrap() []error {
+	return l
+}
+
+// add appends err to l unless it is nil. Another errorList is
+// flattened.
+func (l *errorList) add(err error) {
+	switch e := err.(type) {
+	case nil:
+	case errorList:
+		*l = append(*l, e...)
+	default:
+		*l = append(*l, err)
+	}
+}
+
+// err returns l as an error, or nil when l is empty.
+func (l errorList) err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// setField sets a positional argument and returns the number of
+// arguments consumed and the origin of the value.
+func setField(name string, field reflect.StructField, value reflect.Value, args []string) (int, string, error) {
+	if isSlice(field.Type) {
+		n, err := setSlice(field, value, args)
+		if n == 0 {
+			return 0, fromDefault, newNameError(name, err)
+		}
+		return n, fromArg, newNameError(name, err)
 	}
 	if len(args) == 0 {
-		return 0, newNameError(name, errors.New("missing argument"))
+		if ok, err := setFromEnv(name, field, value); ok {
+			return 0, fromEnv, err
+		}
+		if def, ok := field.Tag.Lookup("default"); ok {
+			return 0, fromDefault, newNameError(name, setFieldValue(field, value, def))
+		}
+		if isOptional(field) {
+			return 0, fromDefault, nil
+		}
+		return 0, "", newNameError(name, errors.New("missing argument"))
 	}
-	return 1, newNameError(name, setValue(value, args[0]))
+	return 1, fromArg, newNameError(name, setFieldValue(field, value, args[0]))
+}
+
+// isOptional tells whether the positional argument of field is
+// optional.
+func isOptional(field reflect.StructField) bool {
+	_, ok := field.Tag.Lookup("default")
+	return ok || field.Tag.Get("optional") == "true"
 }
 
+// setValue sets value from s. A nil pointer is first set to a new
+// value.
 func setValue(value reflect.Value, s string) error {
+	if v := valueOf(value); v != nil {
+		return v.Set(s)
+	}
+	if value.Kind() == reflect.Ptr {
+		return setValue(allocPointer(value), s)
+	}
 	ptr := unsafe.Pointer(value.UnsafeAddr())
+	if value.Type() == durationType {
+		return setDuration(ptr, s)
+	}
 	switch value.Type().Kind() {
 	case reflect.Bool:
 		return setBool(ptr, s)
@@ -239,12 +1016,29 @@ func setValue(value reflect.Value, s string) error {
 	}
 }
 
-func setSlice(value reflect.Value, args []string) (n int, err error) {
+// settable returns a settable value for the addressable value, even
+// when it is a field promoted from an unexported embedded struct, which
+// reflect would otherwise refuse to set or read.
+func settable(value reflect.Value) reflect.Value {
+	return reflect.NewAt(value.Type(), unsafe.Pointer(value.UnsafeAddr())).Elem()
+}
+
+// allocPointer sets the pointer value to a new value if it is nil, and
+// returns the value it points to.
+func allocPointer(value reflect.Value) reflect.Value {
+	value = settable(value)
+	if value.IsNil() {
+		value.Set(reflect.New(value.Type().Elem()))
+	}
+	return value.Elem()
+}
+
+func setSlice(field reflect.StructField, value reflect.Value, args []string) (n int, err error) {
 	value.SetLen(0)
 	slice := value
 	for _, i := range args {
 		x := reflect.New(value.Type().Elem())
-		if err = setValue(x.Elem(), i); err != nil {
+		if err = setFieldValue(field, x.Elem(), i); err != nil {
 			return
 		}
 		slice = reflect.Append(slice, x.Elem())
@@ -262,6 +1056,14 @@ func setBool(ptr unsafe.Pointer, s string) error {
 	return err
 }
 
+func setDuration(ptr unsafe.Pointer, s string) error {
+	d, err := time.ParseDuration(s)
+	if err == nil {
+		*(*time.Duration)(ptr) = d
+	}
+	return err
+}
+
 func setFloat64(ptr unsafe.Pointer, s string) error {
 	f, err := strconv.ParseFloat(s, 64)
 	if err == nil {
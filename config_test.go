@@ -1,10 +1,14 @@
 package easy
 
 import (
+	"bytes"
 	"flag"
+	"io/ioutil"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 type justBool bool
@@ -29,7 +33,42 @@ type badConfig struct {
 	// OK.
 	Bool bool
 	// Not OK.
-	Struct struct{ N, M int }
+	Chan chan int
+}
+
+type Common struct {
+	Verbose bool
+}
+
+type nestedConfig struct {
+	Common
+	DB struct {
+		Host string
+		Port int
+	}
+	Out struct {
+		Name string
+	} `name:"output"`
+}
+
+// upper is a TextUnmarshaler that upper-cases its input.
+type upper string
+
+func (u *upper) UnmarshalText(text []byte) error {
+	*u = upper(strings.ToUpper(string(text)))
+	return nil
+}
+
+type valueConfig struct {
+	Duration time.Duration
+	Strings  Strings
+	Upper    upper
+}
+
+type optionalConfig struct {
+	Input  string
+	Output string `default:"-"`
+	N      int    `optional:"true"`
 }
 
 type sliceConfig struct {
@@ -127,6 +166,303 @@ func TestAddFlags(t *testing.T) {
 	}()
 }
 
+func TestNestedFlags(t *testing.T) {
+	var x nestedConfig
+	fs := flag.NewFlagSet("", 0)
+	AddFlags(&x, fs)
+	for _, name := range []string{"verbose", "db.host", "db.port", "output.name"} {
+		if fs.Lookup(name) == nil {
+			t.Errorf("-%s is not defined", name)
+		}
+	}
+	if err := fs.Parse(strings.Fields("-verbose -db.host=localhost -db.port=1 -output.name=x")); err != nil {
+		t.Errorf("error in parsing flags: %v", err)
+	} else if !x.Verbose || x.DB.Host != "localhost" || x.DB.Port != 1 || x.Out.Name != "x" {
+		t.Errorf("after parsing got %+v", x)
+	}
+	if names := FieldNames(&x); !reflect.DeepEqual(names, []string{"verbose", "db.host", "db.port", "output.name"}) {
+		t.Errorf("unexpected field names %q", names)
+	}
+}
+
+type envConfig struct {
+	Host string `env:"TEST_HOST"`
+	Port int    `env:"TEST_PORT" usage:"port"`
+}
+
+func TestEnv(t *testing.T) {
+	os.Setenv("TEST_HOST", "localhost")
+	os.Setenv("TEST_PORT", "1")
+	defer os.Unsetenv("TEST_HOST")
+	defer os.Unsetenv("TEST_PORT")
+	// Flags.
+	func() {
+		var x envConfig
+		fs := flag.NewFlagSet("", 0)
+		AddFlags(&x, fs)
+		if f := fs.Lookup("port"); f == nil {
+			t.Errorf("-port is not defined")
+		} else if f.Usage != "port (env $TEST_PORT)" {
+			t.Errorf("incorrect usage for -port: %q", f.Usage)
+		}
+		if err := ParseFlagsAndArgsWith("", nil, fs, []string{"-port=2"}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		} else if y := (envConfig{"localhost", 2}); x != y {
+			t.Errorf("after parsing got %+v; expected %+v", x, y)
+		}
+	}()
+	// Arguments.
+	func() {
+		var x envConfig
+		if err := SetArgs(&x, []string{"remote"}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		} else if y := (envConfig{"remote", 1}); x != y {
+			t.Errorf("got %+v; expected %+v", x, y)
+		}
+		os.Setenv("TEST_PORT", "x")
+		if err := SetArgs(&x, []string{"remote"}); err == nil {
+			t.Errorf("expected error")
+		}
+	}()
+}
+
+type gnuConfig struct {
+	Output  string `short:"o"`
+	Verbose bool   `short:"v"`
+	Quiet   bool   `short:"q"`
+	N       int
+}
+
+func TestGNUFlags(t *testing.T) {
+	for _, c := range []struct {
+		args     string
+		expected gnuConfig
+		rest     []string
+	}{
+		{"--output=x -vq --n 1 a", gnuConfig{"x", true, true, 1}, []string{"a"}},
+		{"-o x --verbose -- -q", gnuConfig{"x", true, false, 0}, []string{"-q"}},
+		{"-qox -n=2", gnuConfig{"x", false, true, 2}, nil},
+		{"-vo=x -output y", gnuConfig{"y", true, false, 0}, nil},
+	} {
+		var x gnuConfig
+		fs := flag.NewFlagSet("", flag.ContinueOnError)
+		AddFlags(&x, fs)
+		SetParseMode(fs, GNUFlags)
+		var rest struct{ Rest []string }
+		if err := ParseFlagsAndArgsWith("", &rest, fs, strings.Fields(c.args)); err != nil {
+			t.Errorf("%q: unexpected error: %v", c.args, err)
+		} else if x != c.expected || !reflect.DeepEqual(rest.Rest, c.rest) {
+			t.Errorf("%q: got %+v and %q; expected %+v and %q", c.args, x, rest.Rest, c.expected, c.rest)
+		}
+	}
+	var x gnuConfig
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	AddFlags(&x, fs)
+	SetParseMode(fs, GNUFlags)
+	if err := ParseFlagsAndArgsWith("", nil, fs, []string{"-vz"}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestInterspersed(t *testing.T) {
+	for _, c := range []struct {
+		mode     ParseMode
+		args     string
+		expected gnuConfig
+		rest     []string
+	}{
+		{Interspersed, "a -n 5 b -verbose -output=x", gnuConfig{"x", true, false, 5}, []string{"a", "b"}},
+		{Interspersed, "a -n 5 -- -verbose", gnuConfig{"", false, false, 5}, []string{"a", "-verbose"}},
+		{Interspersed | GNUFlags, "a -vo x b --n=1 -q", gnuConfig{"x", true, true, 1}, []string{"a", "b"}},
+		{0, "a -n 5", gnuConfig{}, []string{"a", "-n", "5"}},
+	} {
+		var x gnuConfig
+		fs := flag.NewFlagSet("", flag.ContinueOnError)
+		AddFlags(&x, fs)
+		SetParseMode(fs, c.mode)
+		var rest struct{ Rest []string }
+		if err := ParseFlagsAndArgsWith("", &rest, fs, strings.Fields(c.args)); err != nil {
+			t.Errorf("%q: unexpected error: %v", c.args, err)
+		} else if x != c.expected || !reflect.DeepEqual(rest.Rest, c.rest) {
+			t.Errorf("%q: got %+v and %q; expected %+v and %q", c.args, x, rest.Rest, c.expected, c.rest)
+		}
+	}
+}
+
+type collectionConfig struct {
+	X      []string
+	N      []int `sep:","`
+	Label  map[string]int
+	Tags   []string
+	Fields map[string]string `sep:";"`
+}
+
+func TestCollectionFlags(t *testing.T) {
+	x := collectionConfig{Tags: []string{"a", "b"}}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	AddFlags(&x, fs)
+	if f := fs.Lookup("tags"); f == nil {
+		t.Errorf("-tags is not defined")
+	} else if f.DefValue != "a,b" {
+		t.Errorf("default value of -tags is %q; expected %q", f.DefValue, "a,b")
+	}
+	if err := fs.Parse(strings.Fields("-x a -x b,c -n 1,2 -n 3 -label a=1 -label b=2 -fields a=x;b=y")); err != nil {
+		t.Errorf("error in parsing flags: %v", err)
+	} else {
+		y := collectionConfig{
+			[]string{"a", "b,c"},
+			[]int{1, 2, 3},
+			map[string]int{"a": 1, "b": 2},
+			[]string{"a", "b"},
+			map[string]string{"a": "x", "b": "y"},
+		}
+		if !reflect.DeepEqual(x, y) {
+			t.Errorf("after parsing got %+v; expected %+v", x, y)
+		}
+	}
+	if f := fs.Lookup("label"); f.Value.String() != "a=1,b=2" {
+		t.Errorf("unexpected value of -label: %q", f.Value.String())
+	}
+	fs.SetOutput(ioutil.Discard)
+	if err := fs.Parse(strings.Fields("-label a")); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestHiddenAndDeprecatedFlags(t *testing.T) {
+	var x struct {
+		Secret  int    `hidden:"true"`
+		Old     string `deprecated:"use -new instead" replacement:"new"`
+		Older   bool   `deprecated:"no longer needed"`
+		New     string
+		Visible int
+	}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	AddFlags(&x, fs)
+	var b bytes.Buffer
+	fs.SetOutput(&b)
+	printDefaults(fs)
+	if s := b.String(); strings.Contains(s, "secret") || !strings.Contains(s, "-visible") {
+		t.Errorf("unexpected usage:\n%s", s)
+	} else if !strings.Contains(s, "-old string  (deprecated: use -new instead)") {
+		t.Errorf("expected deprecation in usage:\n%s", s)
+	}
+	r := &recordingLogger{}
+	Log = r
+	defer func() { Log = nil }()
+	if err := fs.Parse(strings.Fields("-secret=1 -old=x -older")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if x.Secret != 1 || x.Old != "x" || x.New != "x" || !x.Older {
+		t.Errorf("unexpected result %+v", x)
+	}
+	if s := strings.Join(r.messages, "|"); s != "W -old is deprecated: use -new instead|W -older is deprecated: no longer needed" {
+		t.Errorf("unexpected warnings %q", s)
+	}
+}
+
+func TestDeprecatedFlagsFromConfigAndEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "easy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("TEST_DEPRECATED_OLD_PORT", "2")
+	defer os.Unsetenv("TEST_DEPRECATED_OLD_PORT")
+	type config struct {
+		OldHost string `deprecated:"use -host" replacement:"host"`
+		Host    string
+		OldPort int `env:"TEST_DEPRECATED_OLD_PORT" deprecated:"use -port" replacement:"port"`
+		Port    int
+	}
+	r := &recordingLogger{}
+	Log = r
+	defer func() { Log = nil }()
+	var x config
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	AddConfigFlag(fs)
+	AddFlags(&x, fs)
+	conf := writeTemp(t, dir, "a.conf", "oldhost = a\n")
+	if err := ParseFlagsAndArgsWith("", nil, fs, []string{"-config=" + conf}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if x != (config{"a", "a", 2, 2}) {
+		t.Errorf("unexpected result %+v", x)
+	}
+	if s := strings.Join(r.messages, "|"); s != "W -oldhost is deprecated: use -host|W -oldport is deprecated: use -port" {
+		t.Errorf("unexpected warnings %q", s)
+	}
+	// The replacements given explicitly take precedence.
+	var y config
+	fs = flag.NewFlagSet("", flag.ContinueOnError)
+	AddConfigFlag(fs)
+	AddFlags(&y, fs)
+	conf = writeTemp(t, dir, "b.conf", "host = b\noldhost = a\n")
+	if err := ParseFlagsAndArgsWith("", nil, fs, []string{"-config=" + conf, "-port=3"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if y != (config{"a", "b", 2, 3}) {
+		t.Errorf("unexpected result %+v", y)
+	}
+}
+
+func TestPointerFlags(t *testing.T) {
+	var x struct {
+		N       *int `min:"0"`
+		Name    *string
+		Verbose *bool
+		Timeout *time.Duration
+	}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	AddFlags(&x, fs)
+	var args struct {
+		Out *string `optional:"true"`
+	}
+	if err := ParseFlagsAndArgsWith("", &args, fs, strings.Fields("-n=0 -verbose")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if x.N == nil || *x.N != 0 || x.Verbose == nil || !*x.Verbose || x.Name != nil || x.Timeout != nil || args.Out != nil {
+		t.Errorf("unexpected result %+v %+v", x, args)
+	}
+	if s := Specified(&x); !reflect.DeepEqual(s, map[string]bool{"n": true, "verbose": true}) {
+		t.Errorf("got specified flags %v", s)
+	}
+	if s := Specified(&args); len(s) != 0 {
+		t.Errorf("got specified arguments %v", s)
+	}
+	if err := ParseFlagsAndArgsWith("", &args, fs, []string{"a"}); err != nil || args.Out == nil || *args.Out != "a" {
+		t.Errorf("unexpected result %v %+v", err, args)
+	} else if s := Specified(&args); !s["out"] {
+		t.Errorf("got specified arguments %v", s)
+	}
+	// Pointers to groups are not supported.
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	var y struct {
+		DB *struct{ Host string }
+	}
+	AddFlags(&y, flag.NewFlagSet("", 0))
+}
+
+func TestValueFlags(t *testing.T) {
+	x := valueConfig{Duration: time.Second}
+	fs := flag.NewFlagSet("", 0)
+	AddFlags(&x, fs)
+	if f := fs.Lookup("duration"); f == nil {
+		t.Errorf("-duration is not defined")
+	} else if f.DefValue != "1s" {
+		t.Errorf("default value of -duration is %q; expected %q", f.DefValue, "1s")
+	}
+	if err := fs.Parse(strings.Fields("-duration=1m -strings=a,b -upper=quack")); err != nil {
+		t.Errorf("error in parsing flags: %v", err)
+	} else {
+		y := valueConfig{time.Minute, Strings{"a", "b"}, "QUACK"}
+		if !reflect.DeepEqual(x, y) {
+			t.Errorf("after parsing got %+v; expected %+v", x, y)
+		}
+	}
+}
+
 func TestSetArgs(t *testing.T) {
 	// Simple case.
 	func() {
@@ -140,6 +476,54 @@ func TestSetArgs(t *testing.T) {
 			}
 		}
 	}()
+	// Duration, flag.Value and TextUnmarshaler.
+	func() {
+		var c valueConfig
+		if err := SetArgs(&c, []string{"2h", "a,b", "quack"}); err != nil {
+			t.Error("unexpected error: ", err)
+		} else {
+			y := valueConfig{2 * time.Hour, Strings{"a", "b"}, "QUACK"}
+			if !reflect.DeepEqual(c, y) {
+				t.Errorf("expected %+v; got %+v", y, c)
+			}
+		}
+		var d struct{ Rest []time.Duration }
+		if err := SetArgs(&d, []string{"1s", "2ms"}); err != nil {
+			t.Error("unexpected error: ", err)
+		} else if y := []time.Duration{time.Second, 2 * time.Millisecond}; !reflect.DeepEqual(d.Rest, y) {
+			t.Errorf("expected %v; got %v", y, d.Rest)
+		}
+	}()
+	// Optional.
+	func() {
+		c := optionalConfig{N: 1}
+		if err := SetArgs(&c, []string{"in"}); err != nil {
+			t.Error("unexpected error: ", err)
+		} else if y := (optionalConfig{"in", "-", 1}); c != y {
+			t.Errorf("expected %+v; got %+v", y, c)
+		}
+		if err := SetArgs(&c, []string{"in", "out", "2"}); err != nil {
+			t.Error("unexpected error: ", err)
+		} else if y := (optionalConfig{"in", "out", 2}); c != y {
+			t.Errorf("expected %+v; got %+v", y, c)
+		}
+		if err := SetArgs(&c, nil); err == nil {
+			t.Error("expected error")
+		}
+		if names := FieldNames(&c); !reflect.DeepEqual(names, []string{"input", "[output]", "[n]"}) {
+			t.Errorf("unexpected field names %q", names)
+		}
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic")
+			}
+		}()
+		var d struct {
+			A int `optional:"true"`
+			B int
+		}
+		SetArgs(&d, []string{"1", "2"})
+	}()
 	// Slice.
 	func() {
 		var c sliceConfig
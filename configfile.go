@@ -0,0 +1,316 @@
+package easy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AddConfigFlag adds flag -config to fs. When fs is parsed by
+// ParseFlagsAndArgs or ParseFlagsAndArgsWith and -config is given,
+// the named file is loaded into the argument structs added to fs by
+// AddFlags (see LoadConfig). Values in the file override the struct
+// defaults, but not the flags given on the command line or the
+// environment variables named by "env" tags.
+func AddConfigFlag(fs *flag.FlagSet) {
+	name := fs.String("config", "", "load flags from this config file (JSON or key = value)")
+	updateState(fs, func(s *flagSetState) {
+		s.configFile = name
+	})
+}
+
+// LoadConfig loads the config file named name into ptr, which must be
+// a pointer to a struct type. The file is opened with Open. Keys in
+// the file are the flag names of the fields (see AddFlags). Two
+// formats are supported,
+//
+// - JSON, when name has suffix ".json" or the file starts with "{":
+// an object whose values are strings, numbers, booleans or arrays of
+// these (for slice fields); a nested object is a group of flags, or
+// the keys and values of a map field (as in {"label": {"a": 1}});
+//
+// - Otherwise, lines of "key = value", where the value may be a
+// quoted Go string; a line "[section]" makes the following keys
+// belong to group "section", or to map field "section"; lines starting
+// with "#" or ";" are comments.
+//
+// A map field may also be given as a list of "key=value" pairs.
+//
+// Unknown keys and invalid values are reported as *LineError; all of
+// them are reported together, one per line.
+func LoadConfig(name string, ptr interface{}) error {
+	return loadConfig(name, []interface{}{ptr}, map[string]string{})
+}
+
+// loadConfig loads the config file named name into ptrs, except for
+// the fields already given as flags according to origins. origins is
+// updated accordingly.
+func loadConfig(name string, ptrs []interface{}, origins map[string]string) error {
+	r, err := openInput(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var entries []configEntry
+	if strings.HasSuffix(name, ".json") || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		entries, err = parseJSONConfig(data)
+	} else {
+		entries, err = parseKeyValueConfig(bytes.NewReader(data))
+	}
+	if err == nil {
+		err = setConfigEntries(entries, ptrs, origins)
+	}
+	l, ok := err.(errorList)
+	if !ok {
+		return newNameError(name, err)
+	}
+	if len(l) == 1 {
+		return newNameError(name, l[0])
+	}
+	var errs errorList
+	for _, err := range l {
+		errs.add(newNameError(name, err))
+	}
+	return errs
+}
+
+// configEntry is a key and its values read from a config file.
+type configEntry struct {
+	Key    string
+	Values []string
+	Num    int    // 1-based line number.
+	Line   string // the actual line, or the key for JSON.
+}
+
+func setConfigEntries(entries []configEntry, ptrs []interface{}, origins map[string]string) error {
+	fields := fieldsByName(ptrs)
+	var errs errorList
+	for _, e := range mapEntries(entries, fields) {
+		f, ok := fields[e.Key]
+		if !ok {
+			var keys []string
+			for key := range fields {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if guess := suggest(e.Key, keys); guess != "" {
+				errs.add(&LineError{e.Num, e.Line, fmt.Errorf("unknown key %q (did you mean %q?)", e.Key, guess)})
+			} else {
+				errs.add(&LineError{e.Num, e.Line, fmt.Errorf("unknown key %q", e.Key)})
+			}
+			continue
+		}
+		if origins[e.Key] == fromFlag {
+			continue
+		}
+		origins[e.Key] = fromFile
+		err := newNameError(e.Key, setConfigValue(f, e.Values))
+		if err == nil {
+			err = deprecate(e.Key, f.field, fields, origins, fromFile, func(r namedField) error {
+				return setConfigValue(r, e.Values)
+			})
+		}
+		if err != nil {
+			errs.add(&LineError{e.Num, e.Line, err})
+		}
+	}
+	return errs.err()
+}
+
+// mapEntries turns the entries of the members of a map field, e.g.
+// "label.a" from JSON {"label": {"a": 1}}, into key=value pairs of the
+// map field ("label" with "a=1"). Consecutive members of the same map
+// are merged into one entry, so that they are set together.
+func mapEntries(entries []configEntry, fields map[string]namedField) []configEntry {
+	var out []configEntry
+	last := "" // Map field of the last entry in out, if any.
+	for _, e := range entries {
+		key := ""
+		if _, ok := fields[e.Key]; !ok {
+			for i := 0; i < len(e.Key) && key == ""; i++ {
+				if f, ok := fields[e.Key[:i]]; ok && e.Key[i] == '.' && f.field.Type.Kind() == reflect.Map && !isRegistered(f.field.Type) {
+					key = e.Key[:i]
+				}
+			}
+		}
+		if key == "" {
+			out = append(out, e)
+			last = ""
+			continue
+		}
+		var pairs []string
+		for _, v := range e.Values {
+			pairs = append(pairs, e.Key[len(key)+1:]+"="+v)
+		}
+		if key == last {
+			out[len(out)-1].Values = append(out[len(out)-1].Values, pairs...)
+			continue
+		}
+		out = append(out, configEntry{key, pairs, e.Num, e.Line})
+		last = key
+	}
+	return out
+}
+
+// setConfigValue sets field f to the values of a config entry.
+func setConfigValue(f namedField, values []string) error {
+	if isSlice(f.field.Type) {
+		_, err := setSlice(f.field, f.value, values)
+		return err
+	}
+	if f.field.Type.Kind() == reflect.Map {
+		v := newCollectionValue(f.field, f.value)
+		for _, i := range values {
+			if err := v.Set(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(values) != 1 {
+		return errors.New("expected a single value")
+	}
+	return setFieldValue(f.field, f.value, values[0])
+}
+
+// parseKeyValueConfig parses the key = value format. Repeated keys
+// are merged into one entry.
+func parseKeyValueConfig(r io.Reader) ([]configEntry, error) {
+	var entries []configEntry
+	index := map[string]int{}
+	section := ""
+	num := 0
+	err := ForEachLine(r, func(line string) error {
+		num++
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			return nil
+		}
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return errors.New("expected [section]")
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			return nil
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return errors.New("expected key = value")
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if key == "" {
+			return errors.New("empty key")
+		}
+		if section != "" {
+			key = section + "." + key
+		}
+		if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "`") {
+			v, err := strconv.Unquote(value)
+			if err != nil {
+				return err
+			}
+			value = v
+		}
+		if j, ok := index[key]; ok {
+			entries[j].Values = append(entries[j].Values, value)
+		} else {
+			index[key] = len(entries)
+			entries = append(entries, configEntry{key, []string{value}, num, line})
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// parseJSONConfig parses the JSON format, flattening nested objects
+// into dotted keys.
+func parseJSONConfig(data []byte) ([]configEntry, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	lineOf := func() int {
+		return bytes.Count(data[:d.InputOffset()], []byte("\n")) + 1
+	}
+	var entries []configEntry
+	var object func(prefix string) error
+	object = func(prefix string) error {
+		for d.More() {
+			t, err := d.Token()
+			if err != nil {
+				return err
+			}
+			key := prefix + t.(string)
+			num := lineOf()
+			t, err = d.Token()
+			if err != nil {
+				return err
+			}
+			switch t {
+			case json.Delim('{'):
+				if err := object(key + "."); err != nil {
+					return err
+				}
+			case json.Delim('['):
+				values := []string{}
+				for d.More() {
+					t, err := d.Token()
+					if err != nil {
+						return err
+					}
+					v, err := jsonScalar(t)
+					if err != nil {
+						return &LineError{lineOf(), key, err}
+					}
+					values = append(values, v)
+				}
+				if _, err := d.Token(); err != nil {
+					return err
+				}
+				entries = append(entries, configEntry{key, values, num, key})
+			default:
+				if t == nil {
+					continue
+				}
+				v, err := jsonScalar(t)
+				if err != nil {
+					return &LineError{num, key, err}
+				}
+				entries = append(entries, configEntry{key, []string{v}, num, key})
+			}
+		}
+		_, err := d.Token()
+		return err
+	}
+	if t, err := d.Token(); err != nil {
+		return nil, err
+	} else if t != json.Delim('{') {
+		return nil, errors.New("expected a JSON object")
+	}
+	if err := object(""); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func jsonScalar(t json.Token) (string, error) {
+	switch v := t.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("unexpected %v", t)
+}
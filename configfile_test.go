@@ -0,0 +1,135 @@
+package easy
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type fileConfig struct {
+	Name  string
+	Port  int `env:"TEST_FILE_PORT"`
+	Debug bool
+	Tags  []string
+	DB    struct {
+		Host string
+	}
+}
+
+func writeTemp(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "easy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	expected := fileConfig{Name: "x y", Port: 1, Debug: true, Tags: []string{"a", "b"}}
+	expected.DB.Host = "localhost"
+	for _, path := range []string{
+		writeTemp(t, dir, "a.conf", `# comment
+name = "x y"
+port = 1
+debug = true
+tags = a
+tags = b
+
+[db]
+host = localhost
+`),
+		writeTemp(t, dir, "a.json", `{
+  "name": "x y", "port": 1, "debug": true, "tags": ["a", "b"],
+  "db": {"host": "localhost"}
+}`),
+	} {
+		var x fileConfig
+		if err := LoadConfig(path, &x); err != nil {
+			t.Errorf("%s: unexpected error: %v", path, err)
+		} else if !reflect.DeepEqual(x, expected) {
+			t.Errorf("%s: got %+v; expected %+v", path, x, expected)
+		}
+	}
+	// Unknown keys.
+	for _, c := range []struct {
+		content string
+		num     int
+	}{
+		{"name = x\n\nquack = 1\n", 3},
+		{"{\n\"name\": \"x\",\n\"db\": {\n\"quack\": 1}}", 4},
+	} {
+		var x fileConfig
+		err := LoadConfig(writeTemp(t, dir, "b", c.content), &x)
+		if e, ok := err.(*nameError); !ok {
+			t.Errorf("expected *nameError; got %v", err)
+		} else if le, ok := e.Err.(*LineError); !ok || le.Num != c.num {
+			t.Errorf("expected error on line %d; got %v", c.num, err)
+		}
+	}
+	// All the errors are reported together.
+	var y fileConfig
+	err = LoadConfig(writeTemp(t, dir, "b", "quack = 1\nport = x\nmoo = 2\n"), &y)
+	if l, ok := err.(errorList); !ok || len(l) != 3 {
+		t.Errorf("expected 3 errors; got %v", err)
+	} else {
+		for i, err := range l {
+			if e, ok := err.(*nameError); !ok {
+				t.Errorf("expected *nameError; got %v", err)
+			} else if le, ok := e.Err.(*LineError); !ok || le.Num != i+1 {
+				t.Errorf("expected error on line %d; got %v", i+1, err)
+			}
+		}
+	}
+	// Precedence.
+	os.Setenv("TEST_FILE_PORT", "2")
+	defer os.Unsetenv("TEST_FILE_PORT")
+	var x struct {
+		Name string
+		Port int `env:"TEST_FILE_PORT"`
+		DB   struct {
+			Host string
+		}
+	}
+	fs := flag.NewFlagSet("", 0)
+	AddFlags(&x, fs)
+	AddConfigFlag(fs)
+	path := writeTemp(t, dir, "c.conf", "name = x\nport = 1\n[db]\nhost = localhost\n")
+	if err := ParseFlagsAndArgsWith("", nil, fs, []string{"-config", path, "-name=z"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if x.Name != "z" || x.Port != 2 || x.DB.Host != "localhost" {
+		t.Errorf("unexpected result %+v", x)
+	}
+}
+
+func TestLoadConfigMaps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "easy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	type config struct {
+		Label map[string]int
+		Env   map[string]string
+		N     int
+	}
+	expected := config{map[string]int{"a": 1, "b.c": 2}, map[string]string{"x": "y"}, 3}
+	for name, content := range map[string]string{
+		"a.json": `{"label": {"a": 1, "b.c": 2}, "env": ["x=y"], "n": 3}`,
+		"a.conf": "n = 3\nenv = x=y\n[label]\na = 1\nb.c = 2\n",
+	} {
+		var x config
+		if err := LoadConfig(writeTemp(t, dir, name, content), &x); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		} else if !reflect.DeepEqual(x, expected) {
+			t.Errorf("%s: got %+v; expected %+v", name, x, expected)
+		}
+	}
+}
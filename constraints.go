@@ -0,0 +1,153 @@
+package easy
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Kinds of constraints among flags.
+const (
+	exclusive = iota
+	requires
+	atLeastOne
+)
+
+// constraint is a relationship among flags. For requires, the first of
+// names requires the rest.
+type constraint struct {
+	kind  int
+	group string // Group name from field tags, if any.
+	names []string
+}
+
+// Exclusive declares that at most one of the named flags in fs may be
+// given. Like the other constraints, it is checked by
+// ParseFlagsAndArgs and ParseFlagsAndArgsWith, where a flag counts as
+// given when it is set on the command line, in the environment or in
+// the config file. Parsing panics when any of the names is not a flag
+// in fs. The field tag exclusive:"group" does the same for
+// all the fields with the same group.
+func Exclusive(fs *flag.FlagSet, names ...string) {
+	addConstraint(fs, exclusive, "", names...)
+}
+
+// Requires declares that when flag name in fs is given, all of the
+// flags named by others must be given as well. The field tag
+// requires:"a,b" does the same.
+func Requires(fs *flag.FlagSet, name string, others ...string) {
+	addConstraint(fs, requires, "", append([]string{name}, others...)...)
+}
+
+// AtLeastOne declares that at least one of the named flags in fs must
+// be given. The field tag atleastone:"group" does the same for all the
+// fields with the same group.
+func AtLeastOne(fs *flag.FlagSet, names ...string) {
+	addConstraint(fs, atLeastOne, "", names...)
+}
+
+func addConstraint(fs *flag.FlagSet, kind int, group string, names ...string) {
+	updateState(fs, func(s *flagSetState) {
+		if group != "" {
+			for i, c := range s.constraints {
+				if c.kind == kind && c.group == group {
+					s.constraints[i].names = append(c.names, names...)
+					return
+				}
+			}
+		}
+		s.constraints = append(s.constraints, constraint{kind, group, names})
+	})
+}
+
+// addTagConstraints declares the constraints from the tags of a field,
+// whose flag is name.
+func addTagConstraints(fs *flag.FlagSet, name string, tag reflect.StructTag) {
+	if group := tag.Get("exclusive"); group != "" {
+		addConstraint(fs, exclusive, group, name)
+	}
+	if group := tag.Get("atleastone"); group != "" {
+		addConstraint(fs, atLeastOne, group, name)
+	}
+	if others := tag.Get("requires"); others != "" {
+		Requires(fs, name, strings.Split(others, ",")...)
+	}
+}
+
+// checkConstraints checks the constraints of fs given the origins of
+// the flags (see parseFlagsAndArgs) and returns all violations. It
+// panics when a constraint names a flag that is not in fs, which is a
+// mistake of the program, e.g. a misspelled "requires" tag.
+func checkConstraints(fs *flag.FlagSet, origins map[string]string) error {
+	var errs errorList
+	for _, c := range stateOf(fs).constraints {
+		for _, name := range c.names {
+			if fs.Lookup(name) == nil {
+				panic(fmt.Sprintf("constraint on undefined flag -%s", name))
+			}
+		}
+		var given []string
+		for _, name := range c.names {
+			if origins[name] != "" {
+				given = append(given, name)
+			}
+		}
+		switch c.kind {
+		case exclusive:
+			if len(given) > 1 {
+				errs.add(fmt.Errorf("%s cannot be used together", flagList(given, "and")))
+			}
+		case requires:
+			if origins[c.names[0]] == "" {
+				break
+			}
+			var missing []string
+			for _, name := range c.names[1:] {
+				if origins[name] == "" {
+					missing = append(missing, name)
+				}
+			}
+			if len(missing) > 0 {
+				errs.add(newNameError(c.names[0], fmt.Errorf("requires %s", flagList(missing, "and"))))
+			}
+		case atLeastOne:
+			if len(given) == 0 {
+				errs.add(fmt.Errorf("one of %s is required", flagList(c.names, "or")))
+			}
+		}
+	}
+	return errs.err()
+}
+
+// printConstraints describes the constraints of fs for usage.
+func printConstraints(w io.Writer, fs *flag.FlagSet) {
+	constraints := stateOf(fs).constraints
+	if len(constraints) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nConstraints:\n")
+	for _, c := range constraints {
+		switch c.kind {
+		case exclusive:
+			fmt.Fprintf(w, "  at most one of %s\n", flagList(c.names, "or"))
+		case requires:
+			fmt.Fprintf(w, "  -%s requires %s\n", c.names[0], flagList(c.names[1:], "and"))
+		case atLeastOne:
+			fmt.Fprintf(w, "  at least one of %s\n", flagList(c.names, "or"))
+		}
+	}
+}
+
+// flagList formats names as "-a, -b and -c".
+func flagList(names []string, conj string) string {
+	s := make([]string, len(names))
+	for i, name := range names {
+		s[i] = "-" + name
+	}
+	if len(s) == 1 {
+		return s[0]
+	}
+	return strings.Join(s[:len(s)-1], ", ") + " " + conj + " " + s[len(s)-1]
+}
@@ -0,0 +1,88 @@
+package easy
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestConstraints(t *testing.T) {
+	newFlagSet := func() *flag.FlagSet {
+		var x struct {
+			Input     string `exclusive:"input" atleastone:"input"`
+			InputList string `name:"input_list" exclusive:"input" atleastone:"input"`
+			Key       string `requires:"cert"`
+			Cert      string
+			A, B, C   bool
+		}
+		fs := flag.NewFlagSet("", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		AddFlags(&x, fs)
+		Exclusive(fs, "a", "b", "c")
+		return fs
+	}
+	for _, c := range []struct {
+		args   string
+		errors []string
+	}{
+		{"-input=x", nil},
+		{"-input_list=x -key=k -cert=c -a", nil},
+		{"", []string{"one of -input or -input_list is required"}},
+		{"-input=x -input_list=y -key=k -a -b -c", []string{
+			"-input and -input_list cannot be used together",
+			"key: requires -cert",
+			"-a, -b and -c cannot be used together",
+		}},
+	} {
+		err := ParseFlagsAndArgsWith("", nil, newFlagSet(), strings.Fields(c.args))
+		if c.errors == nil {
+			if err != nil {
+				t.Errorf("%q: unexpected error: %v", c.args, err)
+			}
+		} else if err == nil || err.Error() != strings.Join(c.errors, "\n") {
+			t.Errorf("%q: got error %v; expected %q", c.args, err, c.errors)
+		}
+	}
+	fs := newFlagSet()
+	var b bytes.Buffer
+	fs.SetOutput(&b)
+	printDefaults(fs)
+	for _, s := range []string{"at most one of -input or -input_list", "-key requires -cert", "at least one of -input or -input_list"} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("expected %q in usage:\n%s", s, b.String())
+		}
+	}
+}
+
+func TestUndefinedConstraintFlags(t *testing.T) {
+	for _, declare := range []func(fs *flag.FlagSet){
+		func(fs *flag.FlagSet) { Exclusive(fs, "a", "bb") },
+		func(fs *flag.FlagSet) {
+			var x struct {
+				DB struct {
+					Host string `requires:"port"`
+					Port int
+				}
+			}
+			AddFlags(&x, fs)
+		},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			var y struct {
+				A, B bool
+			}
+			fs := flag.NewFlagSet("", flag.ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			AddFlags(&y, fs)
+			declare(fs)
+			ParseFlagsAndArgsWith("", nil, fs, []string{"-a"})
+		}()
+	}
+}
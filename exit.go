@@ -0,0 +1,70 @@
+package easy
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	exitMu    sync.Mutex
+	exitHooks []func()
+)
+
+// AtExit registers f to be run by Cleanup, e.g. to flush and close
+// an output file that would otherwise be left truncated when the
+// program exits early.
+func AtExit(f func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHooks = append(exitHooks, f)
+}
+
+// Cleanup runs the functions registered by AtExit (and those that
+// flush the profiles started by Init), the last registered first. Each of
+// them runs only once, even when Cleanup is called again. Programs
+// using Init should call "defer easy.Cleanup()" in main; Exit and the
+// Fatal methods of the Loggers in this package call Cleanup as well.
+func Cleanup() {
+	exitMu.Lock()
+	hooks := exitHooks
+	exitHooks = nil
+	exitMu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// Exit calls Cleanup and then exits the program with code. This
+// package exits through Exit.
+func Exit(code int) {
+	Cleanup()
+	os.Exit(code)
+}
+
+// InitContext is like Init, but also returns a context that is
+// cancelled when the program receives SIGINT or SIGTERM, so that the
+// program can stop its work and return from main, running its
+// deferred calls (including Cleanup). A second signal makes the
+// program exit immediately through Exit, with code 130.
+func InitContext(ptr interface{}) context.Context {
+	Init(ptr)
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go handleSignals(c, cancel, func() { Exit(130) })
+	return ctx
+}
+
+// handleSignals cancels on the first signal from c and calls exit on
+// the second.
+func handleSignals(c <-chan os.Signal, cancel func(), exit func()) {
+	sig := <-c
+	logger().Warning("received ", sig, "; shutting down (send it again to exit now)")
+	cancel()
+	sig = <-c
+	logger().Warning("received ", sig, " again; exiting")
+	exit()
+}
@@ -0,0 +1,53 @@
+package easy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestHandleSignals(t *testing.T) {
+	Log = &recordingLogger{}
+	defer func() { Log = nil }()
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan os.Signal, 2)
+	exited := make(chan bool)
+	go handleSignals(c, cancel, func() { exited <- true })
+	c <- os.Interrupt
+	<-ctx.Done()
+	select {
+	case <-exited:
+		t.Errorf("exited on the first signal")
+	default:
+	}
+	c <- os.Interrupt
+	<-exited
+}
+
+func TestInitContext(t *testing.T) {
+	if os.Getenv("EASY_TEST_INIT_CONTEXT") == "1" {
+		Log = StdLogger(log.New(os.Stderr, "", 0))
+		AtExit(func() { fmt.Println("cleanup") })
+		ctx := InitContext(nil)
+		self, _ := os.FindProcess(os.Getpid())
+		self.Signal(os.Interrupt)
+		<-ctx.Done()
+		fmt.Println("cancelled")
+		self.Signal(os.Interrupt)
+		time.Sleep(10 * time.Second)
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestInitContext$")
+	cmd.Env = append(os.Environ(), "EASY_TEST_INIT_CONTEXT=1")
+	out, err := cmd.Output()
+	if e, ok := err.(*exec.ExitError); !ok || e.ExitCode() != 130 {
+		t.Errorf("got error %v; expected exit code 130", err)
+	}
+	if string(out) != "cancelled\ncleanup\n" {
+		t.Errorf("got output %q; expected cancelled and then cleanup", out)
+	}
+}
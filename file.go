@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"errors"
 	"io"
+	"io/ioutil"
 	"os"
 	"strings"
 )
@@ -46,6 +47,15 @@ func Open(name string) (io.ReadCloser, error) {
 	}
 }
 
+// openInput is like Open, but closing the result of "-" leaves stdin
+// open, so that it can be read again.
+func openInput(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return Open(name)
+}
+
 // Create opens a file for transparent sequential writing. The
 // returned object can be written and closed much like
 // os.Create. Based on name, it can be,
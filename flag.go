@@ -4,6 +4,8 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -82,3 +84,140 @@ func IntChoice(name string, choices []int, usage string) *int {
 	flag.Var(f, name, fmt.Sprintf("%s (one of %q)", usage, choices))
 	return &f.Value
 }
+
+// newCollectionValue returns a flag.Value for a slice or map field
+// (see AddFlags), or nil when field is neither.
+func newCollectionValue(field reflect.StructField, value reflect.Value) flag.Value {
+	value = settable(value)
+	if isSlice(field.Type) {
+		return &sliceValue{field, value, field.Tag.Get("sep"), false}
+	}
+	if field.Type.Kind() == reflect.Map && !isRegistered(field.Type) {
+		return &mapValue{field, value, field.Tag.Get("sep"), false}
+	}
+	return nil
+}
+
+// pointerValue is a flag for a pointer field, which is left nil until
+// the flag is set.
+type pointerValue struct {
+	field reflect.StructField
+	value reflect.Value
+}
+
+func (p *pointerValue) String() string {
+	if !p.value.IsValid() {
+		return ""
+	}
+	return formatValue(p.value)
+}
+
+func (p *pointerValue) Set(s string) error {
+	return setFieldValue(p.field, p.value, s)
+}
+
+func (p *pointerValue) IsBoolFlag() bool {
+	return p.value.IsValid() && p.value.Type().Elem().Kind() == reflect.Bool
+}
+
+// sliceValue is a repeatable flag that appends to a slice. The first
+// Set discards the default elements. When sep is not empty, each value
+// is further split by sep.
+type sliceValue struct {
+	field reflect.StructField
+	value reflect.Value
+	sep   string
+	set   bool
+}
+
+func (s *sliceValue) String() string {
+	if !s.value.IsValid() {
+		return ""
+	}
+	elems := make([]string, s.value.Len())
+	for i := range elems {
+		elems[i] = formatValue(s.value.Index(i))
+	}
+	return strings.Join(elems, joinSep(s.sep))
+}
+
+func (s *sliceValue) Set(v string) error {
+	if !s.set {
+		s.value.Set(reflect.MakeSlice(s.value.Type(), 0, 0))
+		s.set = true
+	}
+	for _, i := range splitSep(v, s.sep) {
+		x := reflect.New(s.value.Type().Elem()).Elem()
+		if err := setFieldValue(s.field, x, i); err != nil {
+			return err
+		}
+		s.value.Set(reflect.Append(s.value, x))
+	}
+	return nil
+}
+
+// mapValue is a repeatable flag that sets key=value pairs in a
+// map. The first Set discards the default entries. When sep is not
+// empty, each value may contain multiple pairs separated by sep.
+type mapValue struct {
+	field reflect.StructField
+	value reflect.Value
+	sep   string
+	set   bool
+}
+
+func (m *mapValue) String() string {
+	if !m.value.IsValid() {
+		return ""
+	}
+	return strings.Join(mapPairs(m.value), joinSep(m.sep))
+}
+
+// mapPairs formats the entries of map value as sorted key=value
+// pairs. value must be addressable.
+func mapPairs(value reflect.Value) []string {
+	value = settable(value)
+	pairs := []string{}
+	for _, k := range value.MapKeys() {
+		pairs = append(pairs, fmt.Sprint(k.Interface())+"="+fmt.Sprint(value.MapIndex(k).Interface()))
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func (m *mapValue) Set(v string) error {
+	if !m.set || m.value.IsNil() {
+		m.value.Set(reflect.MakeMap(m.value.Type()))
+		m.set = true
+	}
+	for _, i := range splitSep(v, m.sep) {
+		j := strings.Index(i, "=")
+		if j < 0 {
+			return fmt.Errorf("expected key=value; got %q", i)
+		}
+		k := reflect.New(m.value.Type().Key()).Elem()
+		if err := setValue(k, i[:j]); err != nil {
+			return err
+		}
+		x := reflect.New(m.value.Type().Elem()).Elem()
+		if err := setFieldValue(m.field, x, i[j+1:]); err != nil {
+			return err
+		}
+		m.value.SetMapIndex(k, x)
+	}
+	return nil
+}
+
+func splitSep(v, sep string) []string {
+	if sep == "" {
+		return []string{v}
+	}
+	return strings.Split(v, sep)
+}
+
+func joinSep(sep string) string {
+	if sep == "" {
+		return ","
+	}
+	return sep
+}
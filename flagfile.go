@@ -0,0 +1,131 @@
+package easy
+
+import (
+	"errors"
+	"flag"
+	"path/filepath"
+	"strings"
+)
+
+// flagFileName is the name of the built-in flag that reads more flags
+// from a file.
+const flagFileName = "flagfile"
+
+// addFlagFileFlag adds the built-in -flagfile to fs when fs does not
+// have it yet. The flag itself does nothing; files are expanded by
+// expandFlagFiles before parsing.
+func addFlagFileFlag(fs *flag.FlagSet) {
+	if fs.Lookup(flagFileName) == nil {
+		fs.String(flagFileName, "", "read flags from this file, one per line; may be repeated")
+	}
+}
+
+// expandFlagFiles replaces every -flagfile=path (or -flagfile path)
+// among the flags in args with the flags read from path. Each
+// non-empty line of the file that does not start with "#" is one
+// argument, e.g. "-n=5". Flag files may include other flag
+// files. Since the flags are expanded in place, flags after
+// -flagfile override those in the file. With GNUFlags, every flag
+// file is rewritten by gnuArgs.
+func expandFlagFiles(fs *flag.FlagSet, args []string) ([]string, error) {
+	return expandFlagFilesIn(fs, args, nil)
+}
+
+func expandFlagFilesIn(fs *flag.FlagSet, args []string, stack []string) ([]string, error) {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue, ok := splitFlag(args[i])
+		if !ok {
+			// Stop at "--" or the first non-flag, as package flag does.
+			return append(out, args[i:]...), nil
+		}
+		if name != flagFileName {
+			out = append(out, args[i])
+			if !hasValue && takesValue(fs, name) && i+1 < len(args) {
+				i++
+				out = append(out, args[i])
+			}
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return nil, errors.New("flag needs an argument: -" + flagFileName)
+			}
+			i++
+			value = args[i]
+		}
+		expanded, err := readFlagFile(fs, value, stack)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, expanded...)
+	}
+	return out, nil
+}
+
+func readFlagFile(fs *flag.FlagSet, name string, stack []string) ([]string, error) {
+	path := name
+	if name != "-" {
+		if abs, err := filepath.Abs(name); err == nil {
+			path = abs
+		}
+	}
+	for _, i := range stack {
+		if i == path {
+			return nil, newNameError(name, errors.New("cyclic -"+flagFileName))
+		}
+	}
+	r, err := openInput(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var args []string
+	if err := ForEachLine(r, func(line string) error {
+		line = strings.TrimSpace(line)
+		if line != "" && line[0] != '#' {
+			args = append(args, line)
+		}
+		return nil
+	}); err != nil {
+		return nil, newNameError(name, err)
+	}
+	if stateOf(fs).mode&GNUFlags != 0 {
+		if args, err = gnuArgs(fs, args, false); err != nil {
+			return nil, newNameError(name, err)
+		}
+	}
+	return expandFlagFilesIn(fs, args, append(stack, path))
+}
+
+// splitFlag splits a command line argument like -name=value. ok is
+// false when s is not a flag or is the terminator "--".
+func splitFlag(s string) (name, value string, hasValue, ok bool) {
+	if len(s) < 2 || s[0] != '-' || s == "--" {
+		return
+	}
+	name = strings.TrimPrefix(s[1:], "-")
+	if name == "" || name[0] == '-' || name[0] == '=' {
+		return
+	}
+	if i := strings.Index(name, "="); i >= 0 {
+		name, value, hasValue = name[:i], name[i+1:], true
+	}
+	ok = true
+	return
+}
+
+// takesValue tells whether flag name in fs takes the next argument
+// as its value when written as -name.
+func takesValue(fs *flag.FlagSet, name string) bool {
+	f := fs.Lookup(name)
+	if f == nil {
+		return false
+	}
+	if b, ok := f.Value.(interface {
+		IsBoolFlag() bool
+	}); ok && b.IsBoolFlag() {
+		return false
+	}
+	return true
+}
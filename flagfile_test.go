@@ -0,0 +1,40 @@
+package easy
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFlagFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "easy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	inner := writeTemp(t, dir, "inner", "-n=2\n")
+	outer := writeTemp(t, dir, "outer", "# comment\n-s\nfrom file\n\n-flagfile="+inner+"\n-b\n")
+	var x struct {
+		N int
+		S string
+		B bool
+	}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	AddFlags(&x, fs)
+	if err := ParseFlagsAndArgsWith("", nil, fs, []string{"-n=1", "-flagfile", outer, "-b=false"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if x.N != 2 || x.S != "from file" || x.B {
+		t.Errorf("unexpected result %+v", x)
+	}
+	// Cycles.
+	cyclic := filepath.Join(dir, "cyclic")
+	writeTemp(t, dir, "cyclic", "-flagfile="+cyclic+"\n")
+	fs = flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := ParseFlagsAndArgsWith("", nil, fs, []string{"-flagfile=" + cyclic}); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !js && !plan9 && !wasip1
+// +build !js,!plan9,!wasip1
+
+package easy
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyHangup relays SIGHUP to c.
+func notifyHangup(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGHUP)
+}
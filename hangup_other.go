@@ -0,0 +1,10 @@
+//go:build js || plan9 || wasip1
+// +build js plan9 wasip1
+
+package easy
+
+import "os"
+
+// notifyHangup does nothing, as there is no SIGHUP on this system.
+func notifyHangup(c chan<- os.Signal) {
+}
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"log"
 )
 
 type LineError struct {
@@ -55,7 +54,7 @@ func ForEachLineN(r io.Reader, every int, f func(string) error) error {
 		err := f(x)
 		n++
 		if n%every == 0 {
-			log.Print(n)
+			logger().Info(n)
 		}
 		return err
 	})
@@ -67,7 +66,7 @@ func ForEachByteLineN(r io.Reader, every int, f func([]byte) error) error {
 		err := f(x)
 		n++
 		if n%every == 0 {
-			log.Print(n)
+			logger().Info(n)
 		}
 		return err
 	})
@@ -0,0 +1,153 @@
+package easy
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// Logger is where this package writes its log messages, e.g. the
+// command line logged by Init, the progress of ForEachLineN and
+// warnings about deprecated flags. Arguments are handled in the manner
+// of fmt.Print. Fatal exits the program after logging, and should call
+// Cleanup before exiting.
+type Logger interface {
+	Info(args ...interface{})
+	Warning(args ...interface{})
+	Fatal(args ...interface{})
+}
+
+// Log is the Logger used by this package. When it is nil, messages go
+// to the standard logger of package log, unless Init selects another
+// one by its -logger flag.
+var Log Logger
+
+// logger returns Log or the default Logger.
+func logger() Logger {
+	if Log == nil {
+		return StdLogger(nil)
+	}
+	return Log
+}
+
+// loggers maps the names accepted by -logger (see Init) to their
+// Loggers.
+var loggers = map[string]func() Logger{
+	"std":  func() Logger { return StdLogger(nil) },
+	"glog": GlogLogger,
+}
+
+func loggerNames() []string {
+	names := make([]string, 0, len(loggers))
+	for name := range loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StdLogger returns a Logger that writes to l, or to the standard
+// logger of package log when l is nil. Warnings are prefixed by
+// "WARNING: ".
+func StdLogger(l *log.Logger) Logger {
+	return stdLogger{l}
+}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+func (s stdLogger) output(msg string) {
+	if s.l == nil {
+		log.Output(3, msg)
+	} else {
+		s.l.Output(3, msg)
+	}
+}
+
+func (s stdLogger) Info(args ...interface{}) {
+	s.output(fmt.Sprint(args...))
+}
+
+func (s stdLogger) Warning(args ...interface{}) {
+	s.output("WARNING: " + fmt.Sprint(args...))
+}
+
+func (s stdLogger) Fatal(args ...interface{}) {
+	s.output(fmt.Sprint(args...))
+	Exit(1)
+}
+
+// bufferedLogger holds the messages logged while Init parses the
+// flags, before flag -logger takes effect.
+type bufferedLogger struct {
+	mu       sync.Mutex
+	messages []bufferedMessage
+	fallback Logger // Log before buffering.
+}
+
+type bufferedMessage struct {
+	warning bool
+	args    []interface{}
+}
+
+func (b *bufferedLogger) add(warning bool, args []interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, bufferedMessage{warning, args})
+}
+
+func (b *bufferedLogger) Info(args ...interface{}) {
+	b.add(false, args)
+}
+
+func (b *bufferedLogger) Warning(args ...interface{}) {
+	b.add(true, args)
+}
+
+func (b *bufferedLogger) Fatal(args ...interface{}) {
+	b.flush()
+	logger().Fatal(args...)
+}
+
+// flush stops buffering, restoring Log to the fallback unless it is
+// already replaced, and writes the buffered messages to Log.
+func (b *bufferedLogger) flush() {
+	b.mu.Lock()
+	messages := b.messages
+	b.messages = nil
+	b.mu.Unlock()
+	if Log == b {
+		Log = b.fallback
+	}
+	for _, m := range messages {
+		if m.warning {
+			logger().Warning(m.args...)
+		} else {
+			logger().Info(m.args...)
+		}
+	}
+}
+
+// GlogLogger returns a Logger that writes to glog.
+func GlogLogger() Logger {
+	return glogLogger{}
+}
+
+type glogLogger struct{}
+
+func (glogLogger) Info(args ...interface{}) {
+	glog.InfoDepth(1, args...)
+}
+
+func (glogLogger) Warning(args ...interface{}) {
+	glog.WarningDepth(1, args...)
+}
+
+func (glogLogger) Fatal(args ...interface{}) {
+	Cleanup()
+	glog.FatalDepth(1, args...)
+}
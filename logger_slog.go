@@ -0,0 +1,43 @@
+//go:build go1.21
+// +build go1.21
+
+package easy
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+func init() {
+	loggers["slog"] = func() Logger { return SlogLogger(nil) }
+}
+
+// SlogLogger returns a Logger that writes to l, or to slog.Default()
+// when l is nil. Fatal logs at the error level.
+func SlogLogger(l *slog.Logger) Logger {
+	return slogLogger{l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) logger() *slog.Logger {
+	if s.l == nil {
+		return slog.Default()
+	}
+	return s.l
+}
+
+func (s slogLogger) Info(args ...interface{}) {
+	s.logger().Info(fmt.Sprint(args...))
+}
+
+func (s slogLogger) Warning(args ...interface{}) {
+	s.logger().Warn(fmt.Sprint(args...))
+}
+
+func (s slogLogger) Fatal(args ...interface{}) {
+	s.logger().Error(fmt.Sprint(args...))
+	Exit(1)
+}
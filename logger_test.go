@@ -0,0 +1,63 @@
+package easy
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (r *recordingLogger) Info(args ...interface{}) {
+	r.messages = append(r.messages, "I "+fmt.Sprint(args...))
+}
+
+func (r *recordingLogger) Warning(args ...interface{}) {
+	r.messages = append(r.messages, "W "+fmt.Sprint(args...))
+}
+
+func (r *recordingLogger) Fatal(args ...interface{}) {
+	panic(fmt.Sprint(args...))
+}
+
+func TestLogger(t *testing.T) {
+	var b bytes.Buffer
+	l := StdLogger(log.New(&b, "", log.Lshortfile))
+	_, _, line, _ := runtime.Caller(0)
+	l.Info("a", 1)
+	l.Warning("b")
+	if s, e := b.String(), fmt.Sprintf("logger_test.go:%d: a1\nlogger_test.go:%d: WARNING: b\n", line+1, line+2); s != e {
+		t.Errorf("got output %q; expected %q", s, e)
+	}
+	r := &recordingLogger{}
+	Log = r
+	defer func() { Log = nil }()
+	if err := ForEachLineN(strings.NewReader("a\nb\nc\nd\n"), 2, func(string) error { return nil }); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if s := strings.Join(r.messages, "|"); s != "I 2|I 4" {
+		t.Errorf("unexpected messages %q", s)
+	}
+}
+
+func TestBufferedLogger(t *testing.T) {
+	r := &recordingLogger{}
+	b := &bufferedLogger{fallback: r}
+	Log = b
+	defer func() { Log = nil }()
+	logger().Info("a")
+	logger().Warning("b")
+	if len(r.messages) != 0 {
+		t.Errorf("got messages %q before flush", r.messages)
+	}
+	b.flush()
+	b.flush()
+	if s := strings.Join(r.messages, "|"); s != "I a|W b" || Log != r {
+		t.Errorf("got messages %q and Log %v after flush", s, Log)
+	}
+}
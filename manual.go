@@ -0,0 +1,150 @@
+package easy
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// WriteDoc writes a reference of program prog with the subcommands in
+// m (see SubCmd) to w, in format "man" (a roff man page in section 1)
+// or "markdown". Every subcommand is described by its Brief and
+// Detail, as well as its flags and arguments when they are recorded
+// by DescribeCmd.
+func WriteDoc(w io.Writer, format, prog string, m map[string]Cmd) error {
+	return writeDoc(w, format, prog, "", commandInfos(m))
+}
+
+// WriteCommandDoc is like WriteDoc, but for a program without
+// subcommands, described by brief, whose flags are in fs and whose
+// arguments are set to ptr (see ParseFlagsAndArgsWith). fs and ptr
+// may be nil.
+func WriteCommandDoc(w io.Writer, format, prog, brief string, ptr interface{}, fs *flag.FlagSet) error {
+	return writeDoc(w, format, prog, brief, []commandInfo{{"", brief, "", flagInfos(fs), argInfos(ptr)}})
+}
+
+func writeDoc(w io.Writer, format, prog, brief string, cmds []commandInfo) error {
+	prog = filepath.Base(prog)
+	switch format {
+	case "man":
+		return writeManPage(w, prog, brief, cmds)
+	case "markdown":
+		return writeMarkdown(w, prog, brief, cmds)
+	}
+	return fmt.Errorf("unsupported format %q; expected man or markdown", format)
+}
+
+func writeManPage(w io.Writer, prog, brief string, cmds []commandInfo) error {
+	fmt.Fprintf(w, ".TH %s 1\n", roffEscape(strings.ToUpper(prog)))
+	fmt.Fprintf(w, ".SH NAME\n%s", roffEscape(prog))
+	if brief != "" {
+		fmt.Fprintf(w, " \\- %s", roffEscape(brief))
+	}
+	fmt.Fprintf(w, "\n.SH SYNOPSIS\n")
+	for _, c := range cmds {
+		fmt.Fprintf(w, ".B %s\n", roffEscape(strings.TrimSpace(prog+" "+c.Name)))
+		fmt.Fprintf(w, "[flags] %s\n.br\n", roffEscape(c.synopsis()))
+	}
+	sub := len(cmds) != 1 || cmds[0].Name != ""
+	if sub {
+		fmt.Fprintf(w, ".SH COMMANDS\n")
+	}
+	for _, c := range cmds {
+		if sub {
+			fmt.Fprintf(w, ".SS %s\n%s\n", roffEscape(c.Name), roffEscape(c.Brief))
+			if c.Detail != "" {
+				fmt.Fprintf(w, ".PP\n%s\n", roffEscape(c.Detail))
+			}
+		}
+		args, flags := ".SH ARGUMENTS", ".SH FLAGS"
+		if sub {
+			args, flags = ".PP\n.B Arguments:", ".PP\n.B Flags:"
+		}
+		if len(c.Args) > 0 {
+			fmt.Fprintf(w, "%s\n", args)
+			for _, a := range c.Args {
+				fmt.Fprintf(w, ".TP\n.I %s\n%s\n", roffEscape(a.Name), roffEscape(a.Usage))
+			}
+		}
+		if len(c.Flags) > 0 {
+			fmt.Fprintf(w, "%s\n", flags)
+			for _, f := range c.Flags {
+				fmt.Fprintf(w, ".TP\n.B %s\n%s\n", roffEscape(flagSpelling(f)), roffEscape(flagDescription(f)))
+			}
+		}
+	}
+	return nil
+}
+
+func writeMarkdown(w io.Writer, prog, brief string, cmds []commandInfo) error {
+	fmt.Fprintf(w, "# %s\n\n", prog)
+	if brief != "" {
+		fmt.Fprintf(w, "%s\n\n", brief)
+	}
+	sub := len(cmds) != 1 || cmds[0].Name != ""
+	heading := "##"
+	if sub {
+		fmt.Fprintf(w, "## Commands\n\n")
+		for _, c := range cmds {
+			fmt.Fprintf(w, "- [`%s`](#%s): %s\n", c.Name, c.Name, c.Brief)
+		}
+		fmt.Fprintf(w, "\n")
+		heading = "###"
+	}
+	for _, c := range cmds {
+		if sub {
+			fmt.Fprintf(w, "## %s\n\n%s\n\n", c.Name, c.Brief)
+			if c.Detail != "" {
+				fmt.Fprintf(w, "%s\n\n", c.Detail)
+			}
+		}
+		fmt.Fprintf(w, "%s Synopsis\n\n```\n%s [flags] %s\n```\n\n", heading, strings.TrimSpace(prog+" "+c.Name), c.synopsis())
+		if len(c.Args) > 0 {
+			fmt.Fprintf(w, "%s Arguments\n\n", heading)
+			for _, a := range c.Args {
+				fmt.Fprintf(w, "- `%s`: %s\n", a.Name, a.Usage)
+			}
+			fmt.Fprintf(w, "\n")
+		}
+		if len(c.Flags) > 0 {
+			fmt.Fprintf(w, "%s Flags\n\n", heading)
+			for _, f := range c.Flags {
+				fmt.Fprintf(w, "- `%s`: %s\n", flagSpelling(f), flagDescription(f))
+			}
+			fmt.Fprintf(w, "\n")
+		}
+	}
+	return nil
+}
+
+// flagSpelling returns f as written in the usage, with its one-letter
+// alias if any (as in WriteFlags).
+func flagSpelling(f flagInfo) string {
+	if f.Short != "" {
+		return "-" + f.Short + ", -" + f.Name
+	}
+	return "-" + f.Name
+}
+
+// flagDescription returns the usage of f with its default value.
+func flagDescription(f flagInfo) string {
+	if f.DefValue == "" || !f.TakesValue && f.DefValue == "false" {
+		return f.Usage
+	}
+	return appendUsage(f.Usage, fmt.Sprintf("(default %q)", f.DefValue))
+}
+
+// roffEscape escapes s as roff text.
+func roffEscape(s string) string {
+	s = strings.Replace(s, `\`, `\e`, -1)
+	s = strings.Replace(s, "-", `\-`, -1)
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(l, ".") || strings.HasPrefix(l, "'") {
+			lines[i] = `\&` + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
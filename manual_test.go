@@ -0,0 +1,50 @@
+package easy
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestWriteDoc(t *testing.T) {
+	var flags struct {
+		Mode string `usage:"how to run" short:"m"`
+	}
+	fs := flag.NewFlagSet("run", 0)
+	flags.Mode = "fast"
+	AddFlags(&flags, fs)
+	var args struct {
+		Input string   `usage:"input file"`
+		Rest  []string `usage:"more files"`
+	}
+	DescribeCmd("run", fs, &args)
+	defer DescribeCmd("run", nil, nil)
+	m := map[string]Cmd{
+		"run": {Brief: "runs it", Detail: ".dangerous"},
+	}
+	for _, c := range []struct {
+		format   string
+		expected []string
+	}{
+		{"man", []string{".TH MY\\-PROG 1", ".SS run\nruns it", "\\&.dangerous", "[flags] input [rest ...]", ".B \\-m, \\-mode\nhow to run (default \"fast\")"}},
+		{"markdown", []string{"# my-prog", "## run\n\nruns it", "my-prog run [flags] input [rest ...]", "- `input`: input file", "- `-m, -mode`: how to run (default \"fast\")"}},
+	} {
+		var b bytes.Buffer
+		if err := WriteDoc(&b, c.format, "/bin/my-prog", m); err != nil {
+			t.Errorf("%s: unexpected error: %v", c.format, err)
+			continue
+		}
+		for _, s := range c.expected {
+			if !strings.Contains(b.String(), s) {
+				t.Errorf("%s: expected %q in:\n%s", c.format, s, b.String())
+			}
+		}
+	}
+	var b bytes.Buffer
+	if err := WriteCommandDoc(&b, "man", "prog", "does things", &args, nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if s := b.String(); !strings.Contains(s, "prog \\- does things") || !strings.Contains(s, ".SH ARGUMENTS") {
+		t.Errorf("unexpected man page:\n%s", s)
+	}
+}
@@ -0,0 +1,208 @@
+package easy
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HumanNumbers makes every integer field (int, int64, uint and uint64)
+// accept human-friendly numbers, as if the field had a "unit" tag (see
+// AddFlags). It must be set before AddFlags is called.
+var HumanNumbers bool
+
+// ByteSize is a number of bytes that can be written with a decimal or
+// binary suffix, e.g. 1.5G or 64MiB (see AddFlags for the format). It
+// is printed in the same form, e.g. in the usage.
+type ByteSize int64
+
+func (b *ByteSize) Set(s string) error {
+	n, err := parseInt64(s, true)
+	if err == nil {
+		*b = ByteSize(n)
+	}
+	return err
+}
+
+func (b ByteSize) String() string {
+	return formatNumber(int64(b), true)
+}
+
+// Count is a number that can be written with a decimal or binary
+// suffix, e.g. 10k or 2Mi (see AddFlags for the format). It is printed
+// in the same form, e.g. in the usage.
+type Count int64
+
+func (c *Count) Set(s string) error {
+	n, err := parseInt64(s, false)
+	if err == nil {
+		*c = Count(n)
+	}
+	return err
+}
+
+func (c Count) String() string {
+	return formatNumber(int64(c), false)
+}
+
+var numberPattern = regexp.MustCompile(`^([+-]?[0-9_]*\.?[0-9_]*)([kKMGTPE]i?)?$`)
+
+// parseNumber parses a human-friendly integer. It is either an integer
+// with a radix prefix (0x, 0o or 0b), or a decimal number followed by
+// an optional suffix: k (or K), M, G, T, P or E for powers of 1000, or
+// Ki, Mi, Gi, Ti, Pi or Ei for powers of 1024. With bytes, the number
+// may end with B, as in 64MiB. Underscores between digits are
+// ignored.
+func parseNumber(s string, bytes bool) (*big.Int, error) {
+	t := strings.TrimLeft(s, "+-")
+	if len(t) > 2 && t[0] == '0' && strings.IndexByte("xXob", t[1]) >= 0 {
+		n, ok := new(big.Int).SetString(s, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid number %q", s)
+		}
+		return n, nil
+	}
+	t = s
+	if bytes {
+		t = strings.TrimSuffix(t, "B")
+	}
+	m := numberPattern.FindStringSubmatch(t)
+	if m == nil || strings.Trim(m[1], "+-._") == "" {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	r, ok := new(big.Rat).SetString(strings.Replace(m[1], "_", "", -1))
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	if m[2] != "" {
+		r.Mul(r, new(big.Rat).SetInt(unitSize(m[2])))
+	}
+	if !r.IsInt() {
+		return nil, fmt.Errorf("%q is not an integer", s)
+	}
+	return r.Num(), nil
+}
+
+// unitSize returns the multiplier of a suffix like k or Mi.
+func unitSize(suffix string) *big.Int {
+	base := int64(1000)
+	if strings.HasSuffix(suffix, "i") {
+		base = 1024
+	}
+	exp := strings.IndexByte("kMGTPE", suffix[0]) + 1
+	if exp == 0 {
+		exp = 1 // K
+	}
+	return new(big.Int).Exp(big.NewInt(base), big.NewInt(int64(exp)), nil)
+}
+
+func parseInt64(s string, bytes bool) (int64, error) {
+	n, err := parseNumber(s, bytes)
+	if err != nil {
+		return 0, err
+	}
+	if !n.IsInt64() {
+		return 0, fmt.Errorf("%q is out of range", s)
+	}
+	return n.Int64(), nil
+}
+
+// formatNumber formats n with the largest suffix that leaves either an
+// integer or a number below 1000 with at most three decimal places,
+// preferring binary suffixes for bytes, e.g. 1536 is 1.5KiB as bytes
+// and 1.536k otherwise.
+func formatNumber(n int64, bytes bool) string {
+	unit := ""
+	if bytes {
+		unit = "B"
+	}
+	suffixes := []string{"Ei", "Pi", "Ti", "Gi", "Mi", "Ki", "E", "P", "T", "G", "M", "k"}
+	if !bytes {
+		suffixes = append(suffixes[6:], suffixes[:6]...)
+	}
+	x := big.NewInt(n)
+	for _, s := range suffixes {
+		size := unitSize(s)
+		if new(big.Int).Abs(x).Cmp(size) < 0 {
+			continue
+		}
+		r := new(big.Rat).SetFrac(x, size)
+		if r.IsInt() || new(big.Rat).Abs(r).Cmp(big.NewRat(1000, 1)) < 0 && new(big.Rat).Mul(r, big.NewRat(1000, 1)).IsInt() {
+			f, _ := r.Float64()
+			return strconv.FormatFloat(f, 'f', -1, 64) + s + unit
+		}
+	}
+	return strconv.FormatInt(n, 10) + unit
+}
+
+// isInteger tells whether a field of type t is one of the integer
+// types that accept human-friendly numbers.
+func isInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+	default:
+		return false
+	}
+	p := reflect.PtrTo(t)
+	return t != durationType && !isRegistered(t) && !p.Implements(flagValueType) && !p.Implements(textUnmarshalerType)
+}
+
+// setNumber sets the integer value from the human-friendly number s.
+func setNumber(value reflect.Value, s string, bytes bool) error {
+	n, err := parseNumber(s, bytes)
+	if err != nil {
+		return err
+	}
+	value = settable(value)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int64:
+		if !n.IsInt64() || value.OverflowInt(n.Int64()) {
+			return fmt.Errorf("%q is out of range", s)
+		}
+		value.SetInt(n.Int64())
+	default:
+		if !n.IsUint64() || value.OverflowUint(n.Uint64()) {
+			return fmt.Errorf("%q is out of range", s)
+		}
+		value.SetUint(n.Uint64())
+	}
+	return nil
+}
+
+// setFieldValue is like setValue, but accepts human-friendly numbers
+// when field has a "unit" tag or HumanNumbers is set. value is field
+// itself or an element of it.
+func setFieldValue(field reflect.StructField, value reflect.Value, s string) error {
+	unit, ok := field.Tag.Lookup("unit")
+	if !ok && !HumanNumbers || valueOf(value) != nil {
+		return setValue(value, s)
+	}
+	if value.Kind() == reflect.Ptr {
+		value = allocPointer(value)
+	}
+	if isInteger(value.Type()) {
+		return setNumber(value, s, unit == "bytes")
+	}
+	return setValue(value, s)
+}
+
+// numberValue is a flag for an integer field that accepts
+// human-friendly numbers.
+type numberValue struct {
+	field reflect.StructField
+	value reflect.Value
+}
+
+func (n *numberValue) String() string {
+	if !n.value.IsValid() {
+		return ""
+	}
+	return formatValue(n.value)
+}
+
+func (n *numberValue) Set(s string) error {
+	return setFieldValue(n.field, n.value, s)
+}
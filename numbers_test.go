@@ -0,0 +1,93 @@
+package easy
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	for _, c := range []struct {
+		s     string
+		bytes bool
+		n     int64
+	}{
+		{"10", false, 10},
+		{"1_000", false, 1000},
+		{"10k", false, 10000},
+		{"10K", false, 10000},
+		{"1.5G", false, 1500000000},
+		{"2Ki", false, 2048},
+		{"64MiB", true, 64 << 20},
+		{"100B", true, 100},
+		{"0x1f", false, 31},
+		{"0o755", false, 0755},
+		{"0b101", false, 5},
+		{"-3k", false, -3000},
+	} {
+		if n, err := parseInt64(c.s, c.bytes); err != nil {
+			t.Errorf("%q: unexpected error: %v", c.s, err)
+		} else if n != c.n {
+			t.Errorf("%q: got %d; expected %d", c.s, n, c.n)
+		}
+	}
+	for _, s := range []string{"", "k", "1.5", "1.0001k", "10kB", "1x", "0xg"} {
+		if _, err := parseInt64(s, false); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	for _, c := range []struct {
+		n     int64
+		bytes bool
+		s     string
+	}{
+		{0, true, "0B"},
+		{100, true, "100B"},
+		{1536, true, "1.5KiB"},
+		{1500, true, "1.5kB"},
+		{64 << 20, true, "64MiB"},
+		{1536, false, "1.536k"},
+		{10000, false, "10k"},
+		{-2000000, false, "-2M"},
+		{1234567, false, "1234567"},
+	} {
+		if s := formatNumber(c.n, c.bytes); s != c.s {
+			t.Errorf("%d: got %q; expected %q", c.n, s, c.s)
+		}
+	}
+}
+
+func TestHumanNumbers(t *testing.T) {
+	x := struct {
+		Buffer    ByteSize
+		MaxTokens Count `name:"max_tokens"`
+		Limit     int   `unit:"bytes" max:"1GiB"`
+		Plain     int
+	}{Buffer: 64 << 20}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	AddFlags(&x, fs)
+	var b bytes.Buffer
+	fs.SetOutput(&b)
+	printDefaults(fs)
+	if !strings.Contains(b.String(), "(default 64MiB)") {
+		t.Errorf("expected default in usage:\n%s", b.String())
+	}
+	var args struct {
+		N []uint `unit:"count"`
+	}
+	if err := ParseFlagsAndArgsWith("", &args, fs, strings.Fields("-buffer=1G -max_tokens=10k -limit=0x10 2k 3")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if x.Buffer != 1000000000 || x.MaxTokens != 10000 || x.Limit != 16 || len(args.N) != 2 || args.N[0] != 2000 || args.N[1] != 3 {
+		t.Errorf("unexpected result %+v %+v", x, args)
+	}
+	if err := ParseFlagsAndArgsWith("", nil, fs, []string{"-plain=1k"}); err == nil {
+		t.Errorf("expected error for -plain")
+	}
+	if err := ParseFlagsAndArgsWith("", nil, fs, []string{"-limit=2GiB"}); err == nil {
+		t.Errorf("expected error for -limit")
+	}
+}
@@ -0,0 +1,104 @@
+package easy
+
+import (
+	"flag"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// profileFlags are the profiling flags added by Init.
+type profileFlags struct {
+	cpu, heap, block, mutex, trace string
+}
+
+// addProfileFlags adds the profiling flags to fs, except those that
+// fs already has.
+func addProfileFlags(fs *flag.FlagSet) *profileFlags {
+	p := &profileFlags{}
+	for _, f := range []struct {
+		value       *string
+		name, usage string
+	}{
+		{&p.cpu, "cpuprofile", "write a CPU profile to this file"},
+		{&p.heap, "memprofile", "write a heap profile to this file at exit"},
+		{&p.block, "blockprofile", "write a goroutine blocking profile to this file at exit"},
+		{&p.mutex, "mutexprofile", "write a mutex contention profile to this file at exit"},
+		{&p.trace, "trace", "write an execution trace to this file"},
+	} {
+		if fs.Lookup(f.name) == nil {
+			fs.StringVar(f.value, f.name, "", f.usage)
+		}
+	}
+	return p
+}
+
+// start starts the requested profiles and traces, which are stopped
+// and written by Cleanup.
+func (p *profileFlags) start() error {
+	if p.cpu != "" {
+		f, err := os.Create(p.cpu)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		AtExit(func() {
+			pprof.StopCPUProfile()
+			closeProfile(p.cpu, f)
+		})
+	}
+	if p.trace != "" {
+		f, err := os.Create(p.trace)
+		if err != nil {
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return err
+		}
+		AtExit(func() {
+			trace.Stop()
+			closeProfile(p.trace, f)
+		})
+	}
+	if p.heap != "" {
+		AtExit(func() {
+			// Get up-to-date statistics.
+			runtime.GC()
+			writeProfile("heap", p.heap)
+		})
+	}
+	if p.block != "" {
+		runtime.SetBlockProfileRate(1)
+		AtExit(func() { writeProfile("block", p.block) })
+	}
+	if p.mutex != "" {
+		runtime.SetMutexProfileFraction(1)
+		AtExit(func() { writeProfile("mutex", p.mutex) })
+	}
+	return nil
+}
+
+// writeProfile writes the named runtime profile to file name. Errors
+// are logged since it runs at exit.
+func writeProfile(profile, name string) {
+	f, err := os.Create(name)
+	if err != nil {
+		logger().Warning(profile, " profile: ", err)
+		return
+	}
+	if err := pprof.Lookup(profile).WriteTo(f, 0); err != nil {
+		logger().Warning(profile, " profile: ", err)
+	}
+	closeProfile(name, f)
+}
+
+func closeProfile(name string, f *os.File) {
+	if err := f.Close(); err != nil {
+		logger().Warning(name, ": ", err)
+	}
+}
@@ -0,0 +1,45 @@
+package easy
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "easy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	p := addProfileFlags(fs)
+	var names []string
+	for _, name := range []string{"cpuprofile", "memprofile", "blockprofile", "mutexprofile", "trace"} {
+		names = append(names, "-"+name+"="+filepath.Join(dir, name))
+	}
+	if err := fs.Parse(names); err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	AtExit(func() { order = append(order, "first") })
+	if err := p.start(); err != nil {
+		t.Fatal(err)
+	}
+	AtExit(func() { order = append(order, "last") })
+	Cleanup()
+	Cleanup()
+	if s := strings.Join(order, ","); s != "last,first" {
+		t.Errorf("got hooks run in order %q", s)
+	}
+	for _, name := range []string{"cpuprofile", "memprofile", "blockprofile", "mutexprofile", "trace"} {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if fi.Size() == 0 {
+			t.Errorf("%s is empty", name)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package easy
+
+import (
+	"flag"
+	"sync"
+)
+
+// flagSetState is what this package records about a flag set.
+type flagSetState struct {
+	structs     []interface{}     // Argument structs added by AddFlags.
+	defaults    []interface{}     // Copies of structs, as they were before parsing.
+	hidden      map[string]bool   // Hidden flags.
+	shorts      map[string]string // One-letter aliases to full flag names.
+	mode        ParseMode         // Set by SetParseMode.
+	origins     map[string]string // Origins of the flags set by the last parsing.
+	configFile  *string           // Flag -config added by AddConfigFlag.
+	constraints []constraint      // Declared by Exclusive, Requires, etc.
+	args        interface{}       // Argument struct of the last parsing.
+	argOrigins  map[string]string // Origins of the fields of args.
+}
+
+// clone returns a copy of s that shares nothing mutable with s.
+func (s *flagSetState) clone() flagSetState {
+	c := *s
+	c.structs = append([]interface{}(nil), s.structs...)
+	c.defaults = append([]interface{}(nil), s.defaults...)
+	c.hidden = map[string]bool{}
+	for k, v := range s.hidden {
+		c.hidden[k] = v
+	}
+	c.shorts = map[string]string{}
+	for k, v := range s.shorts {
+		c.shorts[k] = v
+	}
+	c.origins = copyOrigins(s.origins)
+	c.argOrigins = copyOrigins(s.argOrigins)
+	c.constraints = make([]constraint, len(s.constraints))
+	for i, k := range s.constraints {
+		k.names = append([]string(nil), k.names...)
+		c.constraints[i] = k
+	}
+	return c
+}
+
+func copyOrigins(origins map[string]string) map[string]string {
+	if origins == nil {
+		return nil
+	}
+	c := map[string]string{}
+	for k, v := range origins {
+		c[k] = v
+	}
+	return c
+}
+
+// registry records the state of the flag sets and the subcommands
+// described by DescribeCmd. It may be used from several goroutines,
+// e.g. by ReloadOnHangup.
+var registry = struct {
+	sync.Mutex
+	flagSets map[*flag.FlagSet]*flagSetState
+	cmds     map[string]cmdArgs
+}{
+	flagSets: map[*flag.FlagSet]*flagSetState{},
+	cmds:     map[string]cmdArgs{},
+}
+
+// stateOf returns a copy of the state of fs, which is empty if fs is
+// unknown.
+func stateOf(fs *flag.FlagSet) flagSetState {
+	registry.Lock()
+	defer registry.Unlock()
+	if s := registry.flagSets[fs]; s != nil {
+		return s.clone()
+	}
+	return flagSetState{}
+}
+
+// updateState calls update with the state of fs, creating it if
+// needed. update must not call back into the registry.
+func updateState(fs *flag.FlagSet, update func(s *flagSetState)) {
+	registry.Lock()
+	defer registry.Unlock()
+	s := registry.flagSets[fs]
+	if s == nil {
+		s = &flagSetState{hidden: map[string]bool{}, shorts: map[string]string{}}
+		registry.flagSets[fs] = s
+	}
+	update(s)
+}
+
+// Release makes this package forget fs, including the argument struct
+// last parsed with it. fs may not be used with this package
+// afterwards, unless added again by AddFlags. Programs that create many
+// short-lived flag sets should release them when done.
+func Release(fs *flag.FlagSet) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.flagSets, fs)
+}
@@ -0,0 +1,56 @@
+package easy
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestRelease(t *testing.T) {
+	var flags struct {
+		Num int `short:"n"`
+	}
+	var args struct {
+		Input string
+	}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	AddFlags(&flags, fs)
+	if err := ParseFlagsAndArgsWith("", &args, fs, []string{"-n", "1", "in"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s := Specified(&flags); !s["num"] {
+		t.Errorf("got specified %v before release; expected num", s)
+	}
+	if s := Specified(&args); !s["input"] {
+		t.Errorf("got specified %v before release; expected input", s)
+	}
+	Release(fs)
+	if s := Specified(&flags); len(s) != 0 {
+		t.Errorf("got specified %v after release; expected none", s)
+	}
+	if s := Specified(&args); len(s) != 0 {
+		t.Errorf("got specified %v after release; expected none", s)
+	}
+}
+
+func TestNoStateWithoutFlagSet(t *testing.T) {
+	registry.Lock()
+	n := len(registry.flagSets)
+	registry.Unlock()
+	for i := 0; i < 10; i++ {
+		var args struct {
+			Input string
+		}
+		if err := ParseFlagsAndArgsWith("", &args, nil, []string{"in"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := SetArgs(&args, []string{"in"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	registry.Lock()
+	m := len(registry.flagSets)
+	registry.Unlock()
+	if m != n {
+		t.Errorf("got %d flag sets after parsing without one; expected %d", m, n)
+	}
+}
@@ -0,0 +1,115 @@
+package easy
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+)
+
+// ReloadOnHangup makes the program reload its flags whenever it
+// receives SIGHUP. ptr must be an argument struct added to
+// flag.CommandLine by AddFlags, and parsed by Init or
+// ParseFlagsAndArgs. On SIGHUP, the command line is parsed again, with
+// flag files, the config file and the environment read again, into
+// fresh copies of the argument structs of flag.CommandLine, starting
+// from their defaults. When this succeeds and the flags are valid,
+// reload is called with the fresh copy of ptr (of the same type as
+// ptr); otherwise the errors are logged (see Log). Either way, ptr
+// itself is left untouched, as are the flags not added by AddFlags.
+// reload is called on a separate goroutine; it may for example send
+// the new struct to a channel. On systems without SIGHUP, such as
+// js/wasm, ReloadOnHangup does nothing.
+func ReloadOnHangup(ptr interface{}, reload func(interface{})) {
+	c := make(chan os.Signal, 1)
+	notifyHangup(c)
+	go func() {
+		for range c {
+			fresh, err := reloadFlags(flag.CommandLine, os.Args[1:], ptr)
+			if err != nil {
+				logger().Warning("reload: ", err)
+				continue
+			}
+			reload(fresh)
+		}
+	}()
+}
+
+// reloadFlags parses args into fresh copies of the argument structs of
+// fs, and returns the copy of ptr.
+func reloadFlags(fs *flag.FlagSet, args []string, ptr interface{}) (interface{}, error) {
+	reloaded := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	reloaded.SetOutput(ioutil.Discard)
+	defer Release(reloaded)
+	state := stateOf(fs)
+	var fresh interface{}
+	for i, p := range state.structs {
+		q := copyStruct(state.defaults[i])
+		AddFlags(q, reloaded)
+		if p == ptr {
+			fresh = q
+		}
+	}
+	if state.configFile != nil {
+		AddConfigFlag(reloaded)
+	}
+	updateState(reloaded, func(s *flagSetState) {
+		// Including those declared by AddFlags above.
+		s.constraints = state.constraints
+		s.mode = state.mode
+	})
+	// Accept, but ignore, the other flags.
+	fs.VisitAll(func(f *flag.Flag) {
+		if reloaded.Lookup(f.Name) == nil && f.Name != flagFileName {
+			reloaded.Var(discardValue{takesValue(fs, f.Name)}, f.Name, f.Usage)
+		}
+	})
+	invalid, err := parseFlags(reloaded, args)
+	if err != nil {
+		return nil, err
+	}
+	if invalid != nil {
+		return nil, invalid
+	}
+	return fresh, nil
+}
+
+// discardValue is a flag that ignores its values.
+type discardValue struct {
+	takesValue bool
+}
+
+func (discardValue) String() string {
+	return ""
+}
+
+func (discardValue) Set(string) error {
+	return nil
+}
+
+func (d discardValue) IsBoolFlag() bool {
+	return !d.takesValue
+}
+
+// copyStruct returns a pointer to a copy of the struct pointed by ptr.
+// Slices and pointers in the fields are copied as well, so that
+// setting the copy does not change the original.
+func copyStruct(ptr interface{}) interface{} {
+	v := reflect.New(reflect.TypeOf(ptr).Elem())
+	v.Elem().Set(reflect.ValueOf(ptr).Elem())
+	forEachField(v.Interface(), func(_ string, field reflect.StructField, value reflect.Value) error {
+		value = settable(value)
+		switch {
+		case value.Kind() == reflect.Ptr && !value.IsNil():
+			p := reflect.New(value.Type().Elem())
+			p.Elem().Set(value.Elem())
+			value.Set(p)
+		case value.Kind() == reflect.Slice && !value.IsNil():
+			s := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+			reflect.Copy(s, value)
+			value.Set(s)
+		}
+		return nil
+	})
+	return v.Interface()
+}
@@ -0,0 +1,90 @@
+package easy
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type reloadConfig struct {
+	N    int
+	Tags []string
+	Name string `requires:"n"`
+}
+
+func TestReloadFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "easy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	conf := writeTemp(t, dir, "a.conf", "n = 1\ntags = a\n")
+	x := reloadConfig{Tags: []string{"default"}}
+	var other bool
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.BoolVar(&other, "other", false, "")
+	AddConfigFlag(fs)
+	AddFlags(&x, fs)
+	args := []string{"-other", "-config=" + conf, "-name=x"}
+	if err := ParseFlagsAndArgsWith("", nil, fs, args); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	writeTemp(t, dir, "a.conf", "n = 2\ntags = b\n")
+	fresh, err := reloadFlags(fs, args, &x)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if y := fresh.(*reloadConfig); !reflect.DeepEqual(*y, reloadConfig{2, []string{"b"}, "x"}) {
+		t.Errorf("got reloaded %+v", *y)
+	}
+	if !reflect.DeepEqual(x, reloadConfig{1, []string{"a"}, "x"}) || !other {
+		t.Errorf("original changed to %+v", x)
+	}
+	writeTemp(t, dir, "a.conf", "tags = c\n")
+	if _, err := reloadFlags(fs, args, &x); err == nil || err.Error() != "name: requires -n" {
+		t.Errorf("got error %v; expected name: requires -n", err)
+	}
+	writeTemp(t, dir, "a.conf", "n = x\n")
+	if _, err := reloadFlags(fs, args, &x); err == nil {
+		t.Errorf("expected error")
+	}
+	if !reflect.DeepEqual(x, reloadConfig{1, []string{"a"}, "x"}) {
+		t.Errorf("original changed to %+v", x)
+	}
+}
+
+// TestReloadConcurrently is meant to be run with -race.
+func TestReloadConcurrently(t *testing.T) {
+	x := reloadConfig{Name: "x"}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	AddFlags(&x, fs)
+	args := []string{"-n=1"}
+	if err := ParseFlagsAndArgsWith("", nil, fs, args); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 20; i++ {
+			if _, err := reloadFlags(fs, args, &x); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for i := 0; i < 20; i++ {
+		var y reloadConfig
+		other := flag.NewFlagSet("", flag.ContinueOnError)
+		AddFlags(&y, other)
+		if err := ParseFlagsAndArgsWith("", nil, other, []string{"-n=2", "-name=y"}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		Settings(other, nil)
+		Specified(&y)
+		Release(other)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("unexpected reload error: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package easy
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Setting is the effective value of a flag or positional argument
+// after parsing, with where it came from.
+type Setting struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	// Elements of a slice or map value, one per repeated flag or
+	// positional argument; nil for other values.
+	Values []string `json:"values,omitempty"`
+	// One of "default", "flag", "positional", "env" or "file".
+	Origin     string `json:"origin"`
+	Positional bool   `json:"positional"`
+}
+
+// Settings returns the effective values of the flags in fs, sorted by
+// name, followed by the positional arguments in ptr, after they are
+// parsed by ParseFlagsAndArgs or ParseFlagsAndArgsWith. fs and ptr may
+// be nil. The origins of positional arguments are only known when ptr
+// is parsed together with fs; SetArgs does not record them.
+func Settings(fs *flag.FlagSet, ptr interface{}) []Setting {
+	settings, _ := collectSettings(fs, ptr)
+	return settings
+}
+
+// collectSettings returns Settings(fs, ptr), and whether each of them
+// is unset, i.e. a nil pointer.
+func collectSettings(fs *flag.FlagSet, ptr interface{}) (settings []Setting, unset []bool) {
+	var argOrigins map[string]string
+	if fs != nil {
+		state := stateOf(fs)
+		if state.args == ptr {
+			argOrigins = state.argOrigins
+		}
+		fields := fieldsByName(state.structs)
+		origins := state.origins
+		given := map[string]bool{}
+		fs.Visit(func(f *flag.Flag) {
+			given[f.Name] = true
+		})
+		fs.VisitAll(func(f *flag.Flag) {
+			if _, ok := state.shorts[f.Name]; ok {
+				return
+			}
+			s := Setting{Name: f.Name, Value: f.Value.String(), Origin: origins[f.Name]}
+			if c, ok := f.Value.(*stringChoice); ok {
+				// Unquoted, as it is given.
+				s.Value = c.Value
+			}
+			if s.Origin == "" {
+				s.Origin = fromDefault
+				if given[f.Name] {
+					s.Origin = fromFlag
+				}
+			}
+			isNil := false
+			if nf, ok := fields[f.Name]; ok {
+				s.Values = elements(nf.field, nf.value)
+				isNil = nf.value.Kind() == reflect.Ptr && nf.value.IsNil()
+			}
+			settings = append(settings, s)
+			unset = append(unset, isNil)
+		})
+	}
+	forEachField(ptr, func(name string, field reflect.StructField, value reflect.Value) error {
+		s := Setting{name, formatValue(value), elements(field, value), argOrigins[name], true}
+		if s.Origin == "" {
+			s.Origin = fromDefault
+		}
+		settings = append(settings, s)
+		unset = append(unset, value.Kind() == reflect.Ptr && value.IsNil())
+		return nil
+	})
+	return settings, unset
+}
+
+// Specified returns the names of the fields of ptr that were given
+// explicitly, i.e. not left to their defaults, when they were last
+// parsed by ParseFlagsAndArgs or ParseFlagsAndArgsWith, as flags added
+// by AddFlags or positional arguments (but not by SetArgs alone, nor
+// with a nil flag set). Fields are named as the flags or in the usage,
+// e.g. "db.host". Values from the environment or a config file count
+// as given.
+func Specified(ptr interface{}) map[string]bool {
+	specified := map[string]bool{}
+	var origins []map[string]string
+	registry.Lock()
+	for _, s := range registry.flagSets {
+		for _, p := range s.structs {
+			if p == ptr {
+				origins = append(origins, copyOrigins(s.origins))
+			}
+		}
+		if s.args == ptr {
+			origins = append(origins, copyOrigins(s.argOrigins))
+		}
+	}
+	registry.Unlock()
+	forEachField(ptr, func(name string, _ reflect.StructField, _ reflect.Value) error {
+		for _, o := range origins {
+			if o[name] != "" && o[name] != fromDefault {
+				specified[name] = true
+			}
+		}
+		return nil
+	})
+	return specified
+}
+
+// elements formats the elements of a slice or map field, or returns
+// nil for other fields.
+func elements(field reflect.StructField, value reflect.Value) []string {
+	if isSlice(field.Type) {
+		s := []string{}
+		for i := 0; i < value.Len(); i++ {
+			s = append(s, formatValue(value.Index(i)))
+		}
+		return s
+	}
+	if field.Type.Kind() == reflect.Map && !isRegistered(field.Type) {
+		return mapPairs(value)
+	}
+	return nil
+}
+
+// WriteSettings writes the effective configuration (see Settings) of
+// program prog to w, in format "json" or "cmdline". The JSON format
+// includes the settings and an equivalent command line; the cmdline
+// format is just the command line. The command line sets every flag
+// and argument explicitly (except -config, -flagfile, -dump_config and
+// unset pointer fields), so that it reproduces the run regardless of
+// the environment and config files.
+func WriteSettings(w io.Writer, format, prog string, fs *flag.FlagSet, ptr interface{}) error {
+	settings, unset := collectSettings(fs, ptr)
+	args := canonicalArgs(settings, unset)
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(struct {
+			CommandLine []string  `json:"command_line"`
+			Settings    []Setting `json:"settings"`
+		}{append([]string{prog}, args...), settings}, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	case "cmdline":
+		quoted := []string{quoteArg(prog)}
+		for _, a := range args {
+			quoted = append(quoted, quoteArg(a))
+		}
+		_, err := fmt.Fprintln(w, strings.Join(quoted, " "))
+		return err
+	}
+	return fmt.Errorf("unsupported format %q; expected json or cmdline", format)
+}
+
+// canonicalArgs returns the command line arguments that reproduce
+// settings, leaving out those that are unset (see collectSettings).
+func canonicalArgs(settings []Setting, unset []bool) []string {
+	var flags, positional []string
+	omitted := false
+	for i, s := range settings {
+		values := s.Values
+		if values == nil {
+			values = []string{s.Value}
+		}
+		if i < len(unset) && unset[i] {
+			// An unset positional argument is optional, and so are the
+			// rest, which are then unset as well.
+			omitted = omitted || s.Positional
+			continue
+		}
+		if s.Positional {
+			if omitted {
+				continue
+			}
+			for _, v := range values {
+				if strings.HasPrefix(v, "@") {
+					// See expandArgFiles.
+					v = "@" + v
+				}
+				positional = append(positional, v)
+			}
+			continue
+		}
+		if s.Name == "config" || s.Name == flagFileName || s.Name == "dump_config" {
+			continue
+		}
+		for _, v := range values {
+			flags = append(flags, "-"+s.Name+"="+v)
+		}
+	}
+	if len(positional) == 0 {
+		return flags
+	}
+	return append(append(flags, "--"), positional...)
+}
+
+// writeSettingsFile writes the settings as JSON to file name, which is
+// created by Create.
+func writeSettingsFile(name string, fs *flag.FlagSet, ptr interface{}) error {
+	w, err := Create(name)
+	if err != nil {
+		return err
+	}
+	if err := WriteSettings(w, "json", os.Args[0], fs, ptr); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+var plainArg = regexp.MustCompile(`^[A-Za-z0-9_./=:,+@%-]+$`)
+
+// quoteArg quotes s for POSIX shells when necessary.
+func quoteArg(s string) string {
+	if plainArg.MatchString(s) {
+		return s
+	}
+	return shellQuote(s)
+}
@@ -0,0 +1,109 @@
+package easy
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSettings(t *testing.T) {
+	os.Setenv("TEST_SETTINGS_USER", "me")
+	defer os.Unsetenv("TEST_SETTINGS_USER")
+	var flags struct {
+		N    int
+		User string `env:"TEST_SETTINGS_USER"`
+		Tags []string
+		Name string
+	}
+	flags.Name = "x"
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	AddFlags(&flags, fs)
+	var args struct {
+		Input string
+		Rest  []string
+	}
+	if err := ParseFlagsAndArgsWith("", &args, fs, []string{"-n", "1", "-tags", "a", "-tags", "b", "in", "c d"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Setting{
+		{"flagfile", "", nil, "default", false},
+		{"n", "1", nil, "flag", false},
+		{"name", "x", nil, "default", false},
+		{"tags", "a,b", []string{"a", "b"}, "flag", false},
+		{"user", "me", nil, "env", false},
+		{"input", "in", nil, "positional", true},
+		{"rest", "[c d]", []string{"c d"}, "positional", true},
+	}
+	if s := Settings(fs, &args); !reflect.DeepEqual(s, expected) {
+		t.Errorf("got settings %+v; expected %+v", s, expected)
+	}
+	var b bytes.Buffer
+	if err := WriteSettings(&b, "cmdline", "prog", fs, &args); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if s, e := b.String(), `prog -n=1 -name=x -tags=a -tags=b -user=me -- in 'c d'`+"\n"; s != e {
+		t.Errorf("got command line %q; expected %q", s, e)
+	}
+}
+
+func TestCanonicalArgs(t *testing.T) {
+	settings := []Setting{
+		{"n", "1", nil, "flag", false},
+		{"rest", "[@weird @@x]", []string{"@weird", "@@x"}, "positional", true},
+	}
+	if a, e := canonicalArgs(settings, nil), []string{"-n=1", "--", "@@weird", "@@@x"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("got args %q; expected %q", a, e)
+	}
+}
+
+func TestCanonicalArgsRoundTrip(t *testing.T) {
+	type config struct {
+		N     *int
+		Limit *int
+		Tags  []string
+		Level string `oneof:"low|high"`
+	}
+	type arguments struct {
+		Input string
+		Out   *string `optional:"true"`
+		Rest  []string
+	}
+	type result struct {
+		Config config
+		Args   arguments
+		Logger string
+	}
+	parse := func(args []string) (result, []string, error) {
+		var r result
+		fs := flag.NewFlagSet("", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		logger := &stringChoice{[]string{"glog", "std"}, "glog"}
+		fs.Var(logger, "logger", "")
+		AddFlags(&r.Config, fs)
+		defer Release(fs)
+		if err := ParseFlagsAndArgsWith("", &r.Args, fs, args); err != nil {
+			return r, nil, err
+		}
+		r.Logger = logger.Value
+		return r, canonicalArgs(collectSettings(fs, &r.Args)), nil
+	}
+	for _, args := range []string{
+		"-limit=3 -tags=a -tags=b -logger=std in",
+		"-level=high in out r1 @@x",
+	} {
+		r, canonical, err := parse(strings.Fields(args))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", args, err)
+			continue
+		}
+		s, _, err := parse(canonical)
+		if err != nil {
+			t.Errorf("%s: unexpected error parsing %q: %v", args, canonical, err)
+		} else if !reflect.DeepEqual(r, s) {
+			t.Errorf("%s: got %+v from %q; expected %+v", args, s, canonical, r)
+		}
+	}
+}
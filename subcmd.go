@@ -1,6 +1,7 @@
 package easy
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"sort"
@@ -13,13 +14,38 @@ type Cmd struct {
 	Action func(args []string)
 }
 
+// DescribeCmd records the flags and argument struct (see
+// ParseFlagsAndArgsWith) of subcommand name, either of which may be
+// nil. They are only used for shell completion and documentation (see
+// WriteCompletion and WriteDoc).
+func DescribeCmd(name string, fs *flag.FlagSet, ptr interface{}) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.cmds[name] = cmdArgs{fs, ptr}
+}
+
+// cmdArgs is what DescribeCmd records about a subcommand.
+type cmdArgs struct {
+	fs  *flag.FlagSet
+	ptr interface{}
+}
+
+// describedCmd returns what DescribeCmd recorded about subcommand name.
+func describedCmd(name string) cmdArgs {
+	registry.Lock()
+	defer registry.Unlock()
+	return registry.cmds[name]
+}
+
 // SubCmd selects the given subcommand named by the first command line
 // argument next to the command name and runs the command by passing
 // the rest of the command line arguments. When no subcommand is
 // given, a list of available subcommands are printed to stderr. There
 // is also a built-in "help" command that either lists the available
 // subcommands or describes a subcommand in more detail. The "help"
-// command can be over-ridden supplying a "help" command in m.
+// command can be over-ridden supplying a "help" command in m. So is
+// the built-in "completion" command, which prints a shell completion
+// script (see WriteCompletion).
 func SubCmd(m map[string]Cmd) {
 	prog := os.Args[0]
 
@@ -28,12 +54,16 @@ func SubCmd(m map[string]Cmd) {
 	if !ok {
 		m["help"] = newHelp(prog, m)
 	}
+	// Likewise for "completion".
+	if _, ok := m["completion"]; !ok {
+		m["completion"] = newCompletion(prog, m)
+	}
 
 	// Show list of available commands when there is no argument.
 	if len(os.Args) <= 1 {
 		fmt.Fprintf(os.Stderr, "Available subcommands of %s:\n", prog)
 		printUsage(m, "")
-		os.Exit(1)
+		Exit(1)
 	}
 
 	// Find and run the command.
@@ -42,7 +72,7 @@ func SubCmd(m map[string]Cmd) {
 	if !ok {
 		fmt.Fprintf(os.Stderr, "Unrecognized subcommand: %q. Run without arguments to see available subcommands. These subcommands partially match yours:\n", cmd)
 		printUsage(m, cmd)
-		os.Exit(1)
+		Exit(1)
 	}
 	sub.Action(os.Args[2:])
 }
@@ -77,7 +107,7 @@ func newHelp(prog string, m map[string]Cmd) Cmd {
 				sub, ok := m[cmd]
 				if !ok {
 					fmt.Fprintf(os.Stderr, "help: unrecognized subcommand: %q; run without arguments to see available subcommands.\n", cmd)
-					os.Exit(1)
+					Exit(1)
 				} else {
 					describeCommand(cmd, sub)
 				}
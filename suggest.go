@@ -0,0 +1,138 @@
+package easy
+
+import (
+	"flag"
+	"fmt"
+)
+
+// parseArgs parses the flags in args into fs, like fs.Parse, but goes
+// on after errors, which are returned together as an errorList. An
+// unknown flag is reported with the closest flag name, if any. When
+// help is requested by -h or -help (unless fs defines them), the usage
+// is printed and flag.ErrHelp is returned alone. The rest of args is
+// left in fs.Args().
+func parseArgs(fs *flag.FlagSet, args []string) error {
+	var errs errorList
+	i := 0
+	for ; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			i++
+			break
+		}
+		name, value, hasValue, ok := splitFlag(a)
+		if !ok {
+			if len(a) > 1 && a[0] == '-' {
+				errs.add(fmt.Errorf("bad flag syntax: %s", a))
+				continue
+			}
+			break
+		}
+		if fs.Lookup(name) == nil {
+			if name == "h" || name == "help" {
+				if fs.Usage != nil {
+					fs.Usage()
+				} else {
+					fs.PrintDefaults()
+				}
+				return flag.ErrHelp
+			}
+			guess := suggest(name, flagNames(fs))
+			if guess == "" {
+				errs.add(fmt.Errorf("flag provided but not defined: -%s", name))
+				continue
+			}
+			errs.add(fmt.Errorf("flag provided but not defined: -%s (did you mean -%s?)", name, guess))
+			// Skip the value of the flag that was probably meant.
+			if !hasValue && takesValue(fs, guess) && i+1 < len(args) {
+				i++
+			}
+			continue
+		}
+		if !takesValue(fs, name) {
+			if !hasValue {
+				value = "true"
+			}
+			if err := fs.Set(name, value); err != nil {
+				errs.add(fmt.Errorf("invalid boolean value %q for -%s: %v", value, name, err))
+			}
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				errs.add(fmt.Errorf("flag needs an argument: -%s", name))
+				continue
+			}
+			i++
+			value = args[i]
+		}
+		if err := fs.Set(name, value); err != nil {
+			errs.add(fmt.Errorf("invalid value %q for flag -%s: %v", value, name, err))
+		}
+	}
+	// Only to leave the rest in fs.Args().
+	fs.Parse(append([]string{"--"}, args[i:]...))
+	return errs.err()
+}
+
+// flagNames returns the names of the visible flags in fs, except the
+// one-letter aliases.
+func flagNames(fs *flag.FlagSet) []string {
+	state := stateOf(fs)
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, ok := state.shorts[f.Name]; !ok && !state.hidden[f.Name] {
+			names = append(names, f.Name)
+		}
+	})
+	return names
+}
+
+// suggest returns the candidate closest to name by edit distance
+// (where swapping adjacent letters counts as one edit), or "" when
+// none is close enough to be a likely typo.
+func suggest(name string, candidates []string) string {
+	best, bestDist := "", (len(name)+2)/3
+	for _, c := range candidates {
+		if d := editDistance(name, c); d <= bestDist && (best == "" || d < bestDist) {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the optimal string alignment distance between a
+// and b.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,86 @@
+package easy
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		d    int
+	}{
+		{"", "", 0},
+		{"output", "output", 0},
+		{"ouptut", "output", 1},
+		{"outptu", "output", 1},
+		{"otput", "output", 1},
+		{"verbose", "v", 6},
+	} {
+		if d := editDistance(c.a, c.b); d != c.d {
+			t.Errorf("editDistance(%q, %q) = %d; expected %d", c.a, c.b, d, c.d)
+		}
+	}
+	if s := suggest("ouptut", []string{"input", "output"}); s != "output" {
+		t.Errorf("got suggestion %q", s)
+	}
+	if s := suggest("xyz", []string{"input", "output"}); s != "" {
+		t.Errorf("got suggestion %q", s)
+	}
+}
+
+func TestAllErrors(t *testing.T) {
+	var x struct {
+		Output  string
+		N       int `max:"10"`
+		Verbose bool
+	}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	AddFlags(&x, fs)
+	var b bytes.Buffer
+	fs.SetOutput(&b)
+	var args struct {
+		A int
+		B int `min:"0"`
+	}
+	err := ParseFlagsAndArgsWith("prog", &args, fs, strings.Fields("-ouptut out -verbose=maybe -n=x -zzz 1 -2"))
+	expected := []string{
+		"flag provided but not defined: -ouptut (did you mean -output?)",
+		`invalid boolean value "maybe" for -verbose: parse error`,
+		`invalid value "x" for flag -n: parse error`,
+		"flag provided but not defined: -zzz",
+		"b: -2 is less than 0",
+	}
+	if err == nil || err.Error() != strings.Join(expected, "\n") {
+		t.Errorf("got error\n%v\nexpected\n%s", err, strings.Join(expected, "\n"))
+	}
+	if s := b.String(); !strings.HasPrefix(s, strings.Join(expected, "\n")+"\nUsage: prog [flags] a b\n") {
+		t.Errorf("unexpected output:\n%s", s)
+	}
+	if u, ok := err.(interface{ Unwrap() []error }); !ok || len(u.Unwrap()) != len(expected) {
+		t.Errorf("expected %d errors from Unwrap", len(expected))
+	}
+	// Errors in positional arguments.
+	var y struct {
+		A, B int
+		C    []int
+	}
+	err = SetArgs(&y, []string{"x", "y", "1", "z"})
+	expected = []string{
+		`a: strconv.ParseInt: parsing "x": invalid syntax`,
+		`b: strconv.ParseInt: parsing "y": invalid syntax`,
+		`c: strconv.ParseInt: parsing "z": invalid syntax`,
+	}
+	if err == nil || err.Error() != strings.Join(expected, "\n") {
+		t.Errorf("got error\n%v\nexpected\n%s", err, strings.Join(expected, "\n"))
+	}
+	// Help.
+	b.Reset()
+	if err := ParseFlagsAndArgsWith("prog", nil, fs, []string{"-help"}); err != flag.ErrHelp {
+		t.Errorf("got error %v; expected flag.ErrHelp", err)
+	} else if !strings.HasPrefix(b.String(), "Usage: prog") {
+		t.Errorf("unexpected output:\n%s", b.String())
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package easy
+
+// termWidth returns 0, as the terminal width is unknown on this
+// system.
+func termWidth(fd uintptr) int {
+	return 0
+}
@@ -0,0 +1,21 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package easy
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// termWidth returns the width of the terminal fd, or 0 when fd is not
+// a terminal.
+func termWidth(fd uintptr) int {
+	var size struct {
+		rows, cols, xpixel, ypixel uint16
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size))); errno != 0 {
+		return 0
+	}
+	return int(size.cols)
+}
@@ -0,0 +1,72 @@
+package easy
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// registeredType is a type registered by RegisterType.
+type registeredType struct {
+	parse  func(string) (interface{}, error)
+	format func(interface{}) string
+}
+
+var registeredTypes = map[reflect.Type]*registeredType{}
+
+// RegisterType registers how to parse and format values of the type of
+// example, so that fields of this type can be used wherever the types
+// supported by AddFlags can: as flags, positional arguments, elements
+// of slices and maps, in the environment and in config files. parse
+// must return a value of the same type as example, or an error;
+// values of other types are reported as errors. When format is nil,
+// values are formatted by fmt.Sprint. A registered type takes
+// precedence over the built-in handling of the type (including
+// flag.Value and encoding.TextUnmarshaler). RegisterType should be
+// called during initialization, e.g. in an init function.
+func RegisterType(example interface{}, parse func(string) (interface{}, error), format func(interface{}) string) {
+	if format == nil {
+		format = func(x interface{}) string {
+			return fmt.Sprint(x)
+		}
+	}
+	registeredTypes[reflect.TypeOf(example)] = &registeredType{parse, format}
+}
+
+func isRegistered(t reflect.Type) bool {
+	_, ok := registeredTypes[t]
+	return ok
+}
+
+// registeredValue is a flag.Value for a field of a registered type.
+type registeredValue struct {
+	value reflect.Value
+	t     *registeredType
+}
+
+func newRegisteredValue(value reflect.Value) *registeredValue {
+	t := registeredTypes[value.Type()]
+	if t == nil {
+		return nil
+	}
+	return &registeredValue{settable(value), t}
+}
+
+func (r *registeredValue) String() string {
+	if !r.value.IsValid() {
+		return ""
+	}
+	return r.t.format(r.value.Interface())
+}
+
+func (r *registeredValue) Set(s string) error {
+	x, err := r.t.parse(s)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(x)
+	if !v.IsValid() || v.Type() != r.value.Type() {
+		return fmt.Errorf("parser of %s returned %T", r.value.Type(), x)
+	}
+	r.value.Set(v)
+	return nil
+}
@@ -0,0 +1,76 @@
+package easy
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type point struct {
+	X, Y int
+}
+
+func init() {
+	RegisterType(point{}, func(s string) (interface{}, error) {
+		var p point
+		if _, err := fmt.Sscanf(s, "%d,%d", &p.X, &p.Y); err != nil {
+			return nil, fmt.Errorf("expected x,y; got %q", s)
+		}
+		return p, nil
+	}, func(x interface{}) string {
+		p := x.(point)
+		return fmt.Sprintf("%d,%d", p.X, p.Y)
+	})
+}
+
+func TestRegisterType(t *testing.T) {
+	x := struct {
+		Origin point
+		Path   []point
+	}{Origin: point{1, 2}}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	AddFlags(&x, fs)
+	var b bytes.Buffer
+	fs.SetOutput(&b)
+	printDefaults(fs)
+	if !strings.Contains(b.String(), `(default 1,2)`) {
+		t.Errorf("expected default in usage:\n%s", b.String())
+	}
+	var args struct {
+		To point
+	}
+	if err := ParseFlagsAndArgsWith("", &args, fs, strings.Fields("-origin=3,4 -path=0,0 -path=1,1 5,6")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if x.Origin != (point{3, 4}) || !reflect.DeepEqual(x.Path, []point{{0, 0}, {1, 1}}) || args.To != (point{5, 6}) {
+		t.Errorf("unexpected result %+v %+v", x, args)
+	}
+	if err := SetArgs(&args, []string{"x"}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+type badType struct {
+	S string
+}
+
+func TestRegisterTypeWrongResult(t *testing.T) {
+	RegisterType(badType{}, func(s string) (interface{}, error) {
+		if s == "nil" {
+			return nil, nil
+		}
+		return s, nil
+	}, nil)
+	var x struct {
+		B badType
+	}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	AddFlags(&x, fs)
+	for _, v := range []string{"x", "nil"} {
+		if err := fs.Set("b", v); err == nil {
+			t.Errorf("%s: expected error", v)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package easy
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// WriteUsage writes the usage of program name to w: the synopsis, the
+// positional arguments in ptr and the flags in fs (see WriteArguments
+// and WriteFlags). ptr and fs may be nil.
+func WriteUsage(w io.Writer, name string, ptr interface{}, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: %s [flags] %s\n", name, strings.Join(FieldNames(ptr), " "))
+	if ptr != nil {
+		fmt.Fprintf(w, "\nArguments:\n")
+		WriteArguments(w, ptr)
+	}
+	if fs != nil {
+		fmt.Fprintf(w, "\nFlags:\n")
+		WriteFlags(w, fs)
+	}
+}
+
+// WriteArguments writes the usage of the positional arguments in ptr
+// to w, one per line with its type, usage and default, in aligned
+// columns wrapped to the terminal width (see terminalWidth). Fields of
+// nested structs are listed under their group.
+func WriteArguments(w io.Writer, ptr interface{}) {
+	var rows []usageRow
+	group := ""
+	forEachField(ptr, func(name string, field reflect.StructField, _ reflect.Value) error {
+		if field.Tag.Get("hidden") == "true" {
+			return nil
+		}
+		typ := typeName(field.Type)
+		if isSlice(field.Type) {
+			typ += "..."
+		}
+		usage := fieldUsage(field)
+		if def, ok := field.Tag.Lookup("default"); ok {
+			usage = appendUsage(usage, fmt.Sprintf("(default %q)", def))
+		}
+		indent := ""
+		if i := strings.LastIndex(name, "."); i < 0 {
+			group = ""
+		} else {
+			if name[:i] != group {
+				group = name[:i]
+				rows = append(rows, usageRow{group + ":", ""})
+			}
+			indent = "  "
+		}
+		rows = append(rows, usageRow{indent + name + " " + typ, usage})
+		return nil
+	})
+	writeTable(w, rows)
+}
+
+// WriteFlags writes the usage of the visible flags in fs to w, in the
+// same layout as WriteArguments. Flags of fields with a "group" tag
+// (see AddFlags) are listed under a heading named by the tag, in the
+// order the groups first appear; the other flags come first. Within
+// them, flags of nested structs (e.g. -db.host) are listed under their
+// parent (db:), as in WriteArguments. Each flag is shown with its
+// one-letter alias, if any, and its default when it is not the zero
+// value. The constraints among flags follow.
+func WriteFlags(w io.Writer, fs *flag.FlagSet) {
+	state := stateOf(fs)
+	fields := fieldsByName(state.structs)
+	var groups []string
+	grouped := map[string][]usageRow{}
+	for _, ptr := range state.structs {
+		forEachField(ptr, func(_ string, field reflect.StructField, _ reflect.Value) error {
+			if g := field.Tag.Get("group"); g != "" && grouped[g] == nil {
+				groups = append(groups, g)
+				grouped[g] = []usageRow{}
+			}
+			return nil
+		})
+	}
+	parents := map[string]string{} // The last parent in each group.
+	aliases := map[string]string{}
+	for short, name := range state.shorts {
+		aliases[name] = short
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if state.hidden[f.Name] {
+			return
+		}
+		if _, ok := state.shorts[f.Name]; ok {
+			return
+		}
+		typ, usage := flag.UnquoteUsage(f)
+		nf, isField := fields[f.Name]
+		if isField && !strings.Contains(f.Usage, "`") {
+			typ = typeName(nf.field.Type)
+			if !takesValue(fs, f.Name) {
+				typ = ""
+			}
+		}
+		g := ""
+		if isField {
+			g = nf.field.Tag.Get("group")
+		}
+		indent := ""
+		if i := strings.LastIndex(f.Name, "."); i < 0 {
+			parents[g] = ""
+		} else {
+			if f.Name[:i] != parents[g] {
+				parents[g] = f.Name[:i]
+				grouped[g] = append(grouped[g], usageRow{parents[g] + ":", ""})
+			}
+			indent = "  "
+		}
+		left := indent + "-" + f.Name
+		if short, ok := aliases[f.Name]; ok {
+			left = indent + "-" + short + ", -" + f.Name
+		}
+		if typ != "" {
+			left += " " + typ
+		}
+		if !isZeroValue(f) {
+			if typ == "string" {
+				usage = appendUsage(usage, fmt.Sprintf("(default %q)", f.DefValue))
+			} else {
+				usage = appendUsage(usage, fmt.Sprintf("(default %v)", f.DefValue))
+			}
+		}
+		grouped[g] = append(grouped[g], usageRow{left, usage})
+	})
+	writeTable(w, grouped[""])
+	for _, g := range groups {
+		fmt.Fprintf(w, "\n%s:\n", g)
+		writeTable(w, grouped[g])
+	}
+	printConstraints(w, fs)
+}
+
+// isZeroValue tells whether the default of f is the zero value of its
+// type, as in package flag. When String panics on the zero value, the
+// default is not shown either.
+func isZeroValue(f *flag.Flag) (zero bool) {
+	defer func() {
+		if recover() != nil {
+			zero = true
+		}
+	}()
+	v := f.Value
+	if d, ok := v.(*deprecatedValue); ok {
+		v = d.Value
+	}
+	t := reflect.TypeOf(v)
+	var z reflect.Value
+	if t.Kind() == reflect.Ptr {
+		z = reflect.New(t.Elem())
+	} else {
+		z = reflect.Zero(t)
+	}
+	return f.DefValue == z.Interface().(flag.Value).String()
+}
+
+// typeName names the type of a field for usage.
+func typeName(t reflect.Type) string {
+	p := reflect.PtrTo(t)
+	switch {
+	case t == durationType:
+		return "duration"
+	case isRegistered(t) || p.Implements(flagValueType) || p.Implements(textUnmarshalerType):
+		if t.Name() != "" {
+			return strings.ToLower(t.Name())
+		}
+		return "value"
+	case t.Kind() == reflect.Ptr || isSlice(t):
+		return typeName(t.Elem())
+	case t.Kind() == reflect.Map:
+		return typeName(t.Key()) + "=" + typeName(t.Elem())
+	case t.Kind() == reflect.Float64:
+		return "float"
+	}
+	return t.Kind().String()
+}
+
+// usageRow is a line in the usage, with a name on the left and a
+// description on the right.
+type usageRow struct {
+	left, right string
+}
+
+// maxUsageLeft is the widest left column that is aligned; wider ones
+// are followed by the description on the next line.
+const maxUsageLeft = 32
+
+// writeTable writes rows indented by two spaces, aligning and
+// wrapping the right column.
+func writeTable(w io.Writer, rows []usageRow) {
+	left := 0
+	for _, r := range rows {
+		if n := len(r.left); n > left && n <= maxUsageLeft && r.right != "" {
+			left = n
+		}
+	}
+	indent := strings.Repeat(" ", 2+left+2)
+	for _, r := range rows {
+		if r.right == "" {
+			fmt.Fprintf(w, "  %s\n", r.left)
+			continue
+		}
+		lines := wrapText(r.right, terminalWidth()-len(indent))
+		if len(r.left) > left {
+			fmt.Fprintf(w, "  %s\n", r.left)
+		} else {
+			fmt.Fprintf(w, "  %-*s  %s\n", left, r.left, lines[0])
+			lines = lines[1:]
+		}
+		for _, l := range lines {
+			fmt.Fprintf(w, "%s%s\n", indent, l)
+		}
+	}
+}
+
+// wrapText breaks s into lines of at most width characters where
+// possible, keeping its line breaks.
+func wrapText(s string, width int) []string {
+	if width < 20 {
+		width = 20
+	}
+	var lines []string
+	for _, p := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(p) {
+			if line != "" && len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// terminalWidth returns the width of the terminal: $COLUMNS when it
+// is set, or else the width of stderr or stdout when either is a
+// terminal, or else 80.
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	for _, f := range []*os.File{os.Stderr, os.Stdout} {
+		if n := termWidth(f.Fd()); n > 0 {
+			return n
+		}
+	}
+	return 80
+}
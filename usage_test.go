@@ -0,0 +1,89 @@
+package easy
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWriteUsage(t *testing.T) {
+	os.Setenv("COLUMNS", "50")
+	defer os.Unsetenv("COLUMNS")
+	x := struct {
+		Verbose bool          `short:"v" usage:"print more"`
+		Timeout time.Duration `usage:"give up after this long" group:"Network"`
+		Host    string        `usage:"the host to connect to, which is a long description that needs wrapping" group:"Network"`
+		Secret  string        `hidden:"true"`
+		N       int
+		DB      struct {
+			Name string `usage:"database name"`
+			Port int    `short:"p"`
+		}
+		Zone string
+	}{Timeout: time.Second, Host: "localhost"}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	AddFlags(&x, fs)
+	var args struct {
+		Input  string `usage:"the input"`
+		Output string `default:"-"`
+		Extra  struct {
+			Paths []string
+		}
+	}
+	var b bytes.Buffer
+	WriteUsage(&b, "prog", &args, fs)
+	expected := `Usage: prog [flags] input [output] [extra.paths ...]
+
+Arguments:
+  input string   the input
+  output string  (default "-")
+  extra:
+    extra.paths string...
+
+Flags:
+  db:
+    -db.name string  database name
+    -p, -db.port int
+  -n int
+  -v, -verbose       print more
+  -zone string
+
+Network:
+  -host string       the host to connect to, which
+                     is a long description that
+                     needs wrapping (default
+                     "localhost")
+  -timeout duration  give up after this long
+                     (default 1s)
+`
+	if b.String() != expected {
+		t.Errorf("got usage\n%s\nexpected\n%s", b.String(), expected)
+	}
+}
+
+// panicValue panics in String when it is the zero value.
+type panicValue struct {
+	s *string
+}
+
+func (v panicValue) String() string {
+	return *v.s
+}
+
+func (v panicValue) Set(s string) error {
+	*v.s = s
+	return nil
+}
+
+func TestWriteFlagsZeroValuePanic(t *testing.T) {
+	s := "x"
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.Var(panicValue{&s}, "p", "usage")
+	var b bytes.Buffer
+	WriteFlags(&b, fs)
+	if e := "  -p value  usage\n"; b.String() != e {
+		t.Errorf("got flags %q; expected %q", b.String(), e)
+	}
+}
@@ -0,0 +1,204 @@
+package easy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Fields of argument structs can be validated with these field tags,
+//
+// - required:"true": the flag must be given, on the command line, in
+// the environment or in the config file (positional arguments are
+// always required);
+//
+// - min:"x" and max:"x": numeric bounds (inclusive), written in the
+// same way as the values;
+//
+// - oneof:"a|b|c": allowed values;
+//
+// - pattern:"re": a regular expression that must match the whole
+// value;
+//
+// - exists:"file", exists:"dir" or exists:"file|dir": the value must
+// name an existing regular file, directory, or either.
+//
+// Empty values are not checked against oneof, pattern or exists. For
+// slices, every element is checked. Malformed tags make AddFlags and
+// SetArgs panic.
+
+// checkTags panics when the validation tags of field, whose flag or
+// argument is name, are malformed, as for unsupported types.
+func checkTags(name string, field reflect.StructField) {
+	t := field.Type
+	if isSlice(t) || t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for _, key := range []string{"min", "max"} {
+		tag := field.Tag.Get(key)
+		if tag == "" {
+			continue
+		}
+		if !isNumeric(t) {
+			panic(fmt.Sprintf("%s: %s on non-numeric type %v", name, key, t))
+		}
+		if err := setFieldValue(field, reflect.New(t).Elem(), tag); err != nil {
+			panic(fmt.Sprintf("%s: invalid %s %q: %v", name, key, tag, err))
+		}
+	}
+	if tag := field.Tag.Get("pattern"); tag != "" {
+		if _, err := regexp.Compile(tag); err != nil {
+			panic(fmt.Sprintf("%s: invalid pattern: %v", name, err))
+		}
+	}
+	if tag := field.Tag.Get("exists"); tag != "" {
+		for _, kind := range strings.Split(tag, "|") {
+			if kind != "file" && kind != "dir" {
+				panic(fmt.Sprintf("%s: invalid exists %q; expected file, dir or file|dir", name, tag))
+			}
+		}
+	}
+}
+
+func isNumeric(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// validate checks the fields of ptr against their validation tags and
+// returns all violations as an errorList. origins tells which flags
+// are given (see parseFlagsAndArgs); when it is nil, every field is
+// considered given.
+func validate(ptr interface{}, origins map[string]string) error {
+	var errs errorList
+	forEachField(ptr, func(name string, field reflect.StructField, value reflect.Value) error {
+		if field.Tag.Get("required") == "true" && origins != nil && origins[name] == "" {
+			errs.add(newNameError(name, errors.New("required but not given")))
+		}
+		if isSlice(field.Type) {
+			for i := 0; i < value.Len(); i++ {
+				errs.add(newNameError(name, checkValue(field, value.Index(i))))
+			}
+		} else {
+			errs.add(newNameError(name, checkValue(field, value)))
+		}
+		return nil
+	})
+	return errs.err()
+}
+
+func checkValue(field reflect.StructField, value reflect.Value) error {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if tag := field.Tag.Get("min"); tag != "" && compareValues(value, parseBound(field, tag, value.Type())) < 0 {
+		return fmt.Errorf("%s is less than %s", formatValue(value), tag)
+	}
+	if tag := field.Tag.Get("max"); tag != "" && compareValues(value, parseBound(field, tag, value.Type())) > 0 {
+		return fmt.Errorf("%s is greater than %s", formatValue(value), tag)
+	}
+	s := formatValue(value)
+	if s == "" {
+		return nil
+	}
+	if tag := field.Tag.Get("oneof"); tag != "" {
+		found := false
+		for _, i := range strings.Split(tag, "|") {
+			if i == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%q is not one of %q", s, strings.Split(tag, "|"))
+		}
+	}
+	if tag := field.Tag.Get("pattern"); tag != "" && !regexp.MustCompile("^(?:"+tag+")$").MatchString(s) {
+		return fmt.Errorf("%q does not match pattern %q", s, tag)
+	}
+	if tag := field.Tag.Get("exists"); tag != "" {
+		fi, err := os.Stat(s)
+		if err != nil {
+			return err
+		}
+		kinds := strings.Split(tag, "|")
+		if len(kinds) == 1 {
+			if kinds[0] == "file" && !fi.Mode().IsRegular() {
+				return fmt.Errorf("%q is not a regular file", s)
+			}
+			if kinds[0] == "dir" && !fi.IsDir() {
+				return fmt.Errorf("%q is not a directory", s)
+			}
+		}
+	}
+	return nil
+}
+
+// parseBound parses a min or max tag of field, already checked by
+// checkTags, as a value of type t.
+func parseBound(field reflect.StructField, tag string, t reflect.Type) reflect.Value {
+	v := reflect.New(t).Elem()
+	setFieldValue(field, v, tag)
+	return v
+}
+
+// compareValues compares two numeric values of the same type (see
+// isNumeric).
+func compareValues(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return sign(a.Int() < b.Int(), a.Int() > b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return sign(a.Uint() < b.Uint(), a.Uint() > b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return sign(a.Float() < b.Float(), a.Float() > b.Float())
+	}
+	panic(fmt.Sprintf("min/max on non-numeric type: %v", a.Type()))
+}
+
+func sign(less, greater bool) int {
+	if less {
+		return -1
+	} else if greater {
+		return 1
+	}
+	return 0
+}
+
+// validationUsage describes the validation tags of field for usage.
+func validationUsage(field reflect.StructField) string {
+	var notes []string
+	if field.Tag.Get("required") == "true" {
+		notes = append(notes, "required")
+	}
+	if tag := field.Tag.Get("min"); tag != "" {
+		notes = append(notes, "min "+tag)
+	}
+	if tag := field.Tag.Get("max"); tag != "" {
+		notes = append(notes, "max "+tag)
+	}
+	if tag := field.Tag.Get("oneof"); tag != "" {
+		notes = append(notes, fmt.Sprintf("one of %q", strings.Split(tag, "|")))
+	}
+	if tag := field.Tag.Get("pattern"); tag != "" {
+		notes = append(notes, fmt.Sprintf("matching %q", tag))
+	}
+	if tag := field.Tag.Get("exists"); tag != "" {
+		notes = append(notes, "existing "+strings.Replace(tag, "|", " or ", -1))
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(notes, ", ") + ")"
+}
@@ -0,0 +1,105 @@
+package easy
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+type validConfig struct {
+	Name string  `required:"true"`
+	N    int     `min:"1" max:"10"`
+	Rate float64 `min:"0" max:"1"`
+	Mode string  `oneof:"fast|slow"`
+	ID   string  `pattern:"[a-z]+[0-9]*"`
+	Dir  string  `exists:"dir"`
+}
+
+func TestValidate(t *testing.T) {
+	// Valid.
+	func() {
+		var x validConfig
+		fs := flag.NewFlagSet("", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		AddFlags(&x, fs)
+		if err := ParseFlagsAndArgsWith("", nil, fs, strings.Fields("-name=a -n=1 -mode=slow -id=abc12 -dir="+os.TempDir())); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}()
+	// All violations at once.
+	func() {
+		var x validConfig
+		fs := flag.NewFlagSet("", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		AddFlags(&x, fs)
+		err := ParseFlagsAndArgsWith("", nil, fs, strings.Fields("-n=11 -rate=-1 -mode=quack -id=1a -dir=/no/such/dir"))
+		if l, ok := err.(errorList); !ok {
+			t.Errorf("expected errorList; got %v", err)
+		} else if len(l) != 6 {
+			t.Errorf("expected 6 errors; got %v", err)
+		} else if e, ok := l[0].(*nameError); !ok || e.Name != "name" {
+			t.Errorf("expected error for name; got %v", l[0])
+		}
+	}()
+	// Arguments.
+	func() {
+		var x struct {
+			Files []int `max:"3"`
+		}
+		if err := SetArgs(&x, []string{"1", "4", "5"}); err == nil {
+			t.Errorf("expected error")
+		} else if l, ok := err.(errorList); !ok || len(l) != 2 {
+			t.Errorf("expected 2 errors; got %v", err)
+		}
+		// Omitted optional arguments are not checked, unless set by a
+		// "default" tag.
+		var y struct {
+			N int `optional:"true" min:"1"`
+		}
+		if err := SetArgs(&y, nil); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		var z struct {
+			N int `default:"0" min:"1"`
+		}
+		if err := SetArgs(&z, nil); err == nil {
+			t.Errorf("expected error")
+		}
+	}()
+	// Usage.
+	func() {
+		var x validConfig
+		fs := flag.NewFlagSet("", 0)
+		fs.SetOutput(ioutil.Discard)
+		AddFlags(&x, fs)
+		if f := fs.Lookup("n"); f.Usage != "(min 1, max 10)" {
+			t.Errorf("incorrect usage for -n: %q", f.Usage)
+		}
+	}()
+	// Malformed tags.
+	for _, x := range []interface{}{
+		&struct {
+			ID string `pattern:"[a-z"`
+		}{},
+		&struct {
+			N int `min:"x"`
+		}{},
+		&struct {
+			Name string `max:"3"`
+		}{},
+		&struct {
+			Path string `exists:"fiel"`
+		}{},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%T: expected panic", x)
+				}
+			}()
+			AddFlags(x, flag.NewFlagSet("", 0))
+		}()
+	}
+}